@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type priceDrop struct {
+	Trip     FlightToCompare
+	OldTotal float64
+	NewTotal float64
+}
+
+func (drop priceDrop) percentChange() float64 {
+	return (drop.NewTotal - drop.OldTotal) / drop.OldTotal * 100
+}
+
+// previousLegPrice returns the price of the flight at its previous observation, in the flight currency.
+type previousLegPrice func(flight Outbound) (float64, bool)
+
+// findPriceDrops returns trips whose total dropped by at least minDropPercent,
+// from the biggest drop to the smallest.
+func findPriceDrops(flightsToCompare map[Bucket][]FlightToCompare, previous previousLegPrice, minDropPercent float64) []priceDrop {
+	var drops []priceDrop
+	for _, trips := range flightsToCompare {
+		for _, trip := range trips {
+			abroadPrevious, abroadSeen := previous(trip.AbroadFlight)
+			returnPrevious, returnSeen := previous(trip.ReturnFlight)
+			if !abroadSeen && !returnSeen {
+				continue
+			}
+			if !abroadSeen {
+				abroadPrevious = trip.AbroadFlight.Price.Value
+			}
+			if !returnSeen {
+				returnPrevious = trip.ReturnFlight.Price.Value
+			}
+			drop := priceDrop{
+				Trip:     trip,
+				OldTotal: math.Round((abroadPrevious+returnPrevious)*100) / 100,
+				NewTotal: trip.totalPrice(),
+			}
+			if drop.OldTotal > 0 && -drop.percentChange() >= minDropPercent && drop.NewTotal < drop.OldTotal {
+				drops = append(drops, drop)
+			}
+		}
+	}
+	sort.SliceStable(drops, func(i, j int) bool {
+		if drops[i].percentChange() != drops[j].percentChange() {
+			return drops[i].percentChange() < drops[j].percentChange()
+		}
+		return drops[i].NewTotal < drops[j].NewTotal
+	})
+	return drops
+}
+
+// previousPriceFromHistory prefers our own last observation of the flight and falls back to
+// the previousPrice reported by ryanair. Observations in EUR are converted with euroRate.
+func previousPriceFromHistory(store *historyStore, now time.Time, euroRate float64) previousLegPrice {
+	return func(flight Outbound) (float64, bool) {
+		if store != nil {
+			observation, err := store.lastObservationBefore(flight.FlightKey, now)
+			if err == nil && observation != nil {
+				price := observation.Price
+				if observation.Currency == "EUR" && flight.Price.CurrencyCode == "PLN" {
+					price = math.Round(price*euroRate*100) / 100
+				}
+				return price, true
+			}
+		}
+		if flight.PreviousPrice != nil {
+			return *flight.PreviousPrice, true
+		}
+		return 0, false
+	}
+}
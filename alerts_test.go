@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_findPriceDrops(t *testing.T) {
+	flights := map[Bucket][]FlightToCompare{
+		{Year: 2024, Month: time.October}: {
+			getMockTrip("2024-10-01T12:00:00", 100, "2024-10-05T12:00:00", 100),
+			getMockTrip("2024-10-02T12:00:00", 90, "2024-10-06T12:00:00", 100),
+			getMockTrip("2024-10-03T12:00:00", 100, "2024-10-07T12:00:00", 100),
+		},
+	}
+	previousPrices := map[string]float64{
+		// 250 -> 200, -20%
+		flights[Bucket{Year: 2024, Month: time.October}][0].AbroadFlight.FlightKey: 150,
+		// 200 -> 190, -5%
+		flights[Bucket{Year: 2024, Month: time.October}][1].AbroadFlight.FlightKey: 100,
+		// 180 -> 200, price went up
+		flights[Bucket{Year: 2024, Month: time.October}][2].ReturnFlight.FlightKey: 80,
+	}
+	previous := func(flight Outbound) (float64, bool) {
+		price, ok := previousPrices[flight.FlightKey]
+		return price, ok
+	}
+
+	drops := findPriceDrops(flights, previous, 5)
+	if len(drops) != 2 {
+		t.Fatalf("price drops length, got: %d != want: %d", len(drops), 2)
+	}
+	if drops[0].OldTotal != 250 || drops[0].NewTotal != 200 || drops[0].percentChange() != -20 {
+		t.Errorf("biggest price drop, got: %+v", drops[0])
+	}
+	if drops[1].OldTotal != 200 || drops[1].NewTotal != 190 {
+		t.Errorf("second price drop, got: %+v", drops[1])
+	}
+
+	if drops := findPriceDrops(flights, previous, 10); len(drops) != 1 {
+		t.Errorf("price drops of at least 10%%, got: %d != want: %d", len(drops), 1)
+	}
+}
+
+func Test_runPriceDrops_limitsDropsNotSentYet(t *testing.T) {
+	defer func(previous Config) { config = previous }(config)
+	defer func(previous io.Writer) { previewOutput = previous }(previewOutput)
+	var output bytes.Buffer
+	previewOutput = &output
+	config = defaultConfig()
+	config.Dedupe = DedupeConfig{Enabled: true, MinPriceChange: 10, CooldownHours: 24}
+	config.Alerts.MaxPriceDrops = 1
+	now := time.Date(2024, time.October, 1, 8, 0, 0, 0, time.UTC)
+
+	withPrevious := func(fare Fare, previous float64) Fare {
+		fare.Outbound.PreviousPrice = &previous
+		return fare
+	}
+	fares := legFares{
+		{"WMI", "ALC"}: {
+			withPrevious(mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN"), 200),
+			withPrevious(mockFare("WMI", "ALC", "2024-10-05T12:00:00", 80, "PLN"), 150),
+		},
+		{"ALC", "WMI"}: {mockFare("ALC", "WMI", "2024-10-10T12:00:00", 50, "PLN")},
+	}
+	trips, err := Preferences{}.trips(fares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drops := findPriceDrops(trips, previousPriceFromHistory(nil, now, 4.3), 0)
+	if len(drops) != 2 {
+		t.Fatalf("price drops, got: %d != want: %d", len(drops), 2)
+	}
+	var state State
+	state.markNotified("a", []FlightToCompare{drops[0].Trip}, now.Add(-time.Hour))
+
+	targets := []target{{"a", stdoutNotifier{"a"}, Preferences{}, plainFormatter{}}}
+	if err := runPriceDrops(now, fares, 4.3, targets, &state); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), "2024-10-05 12:00") || strings.Contains(output.String(), "2024-10-04 12:00") {
+		t.Errorf("the drop not sent yet should be sent instead of the sent one:\n%s", output.String())
+	}
+}
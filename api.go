@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	serverShutdownTimeout   = 30 * time.Second
+	serverReadHeaderTimeout = 10 * time.Second
+)
+
+// searchResponse is the JSON answer of GET /search, trips are ranked with the configured strategy.
+type searchResponse struct {
+	From      []string     `json:"from"`
+	To        []string     `json:"to"`
+	MinNights int          `json:"minNights"`
+	MaxNights int          `json:"maxNights"`
+	Month     string       `json:"month,omitempty"`
+	EuroRate  float64      `json:"euroRate"`
+	Trips     []tripRecord `json:"trips"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiHandler serves searches and fare history as JSON:
+//
+//	GET /search?from=WAW,WMI&to=ALC&min=3&max=15&month=oct
+//	GET /history/{flightKey}
+//	GET /health
+//	GET /metrics
+func apiHandler(strategy RankingStrategy) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", metricsHandler)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /search", func(w http.ResponseWriter, r *http.Request) {
+		request, err := parseSearchQuery(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		trips, euroRate, err := searchTrips(time.Now(), request)
+		if err != nil {
+			slog.Error("Search failed", "from", strings.Join(request.From, ","), "to", strings.Join(request.To, ","), "error", err)
+			writeJSON(w, http.StatusBadGateway, apiError{"could not fetch fares"})
+			return
+		}
+		rankTrips(trips, strategy)
+		response := searchResponse{
+			From:      request.From,
+			To:        request.To,
+			MinNights: request.MinNights,
+			MaxNights: request.MaxNights,
+			EuroRate:  euroRate,
+			Trips:     make([]tripRecord, 0, len(trips)),
+		}
+		if request.Month != 0 {
+			response.Month = request.Month.String()
+		}
+		for _, trip := range trips {
+			response.Trips = append(response.Trips, newTripRecord(trip, euroRate))
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+	// flight keys contain slashes, e.g. FR~1001~ ~~WMI~10/04/2024 12:00~ALC~10/04/2024 15:30~~
+	mux.HandleFunc("GET /history/{flightKey...}", func(w http.ResponseWriter, r *http.Request) {
+		if config.History.Path == "" {
+			writeJSON(w, http.StatusNotFound, apiError{"fare history is not configured"})
+			return
+		}
+		store, err := openHistoryStoreReadOnly(config.History.Path)
+		if err != nil {
+			slog.Error("Could not open fare history", "error", err)
+			writeJSON(w, http.StatusServiceUnavailable, apiError{"fare history is not available"})
+			return
+		}
+		defer store.Close()
+		observations, err := store.flightHistory(r.PathValue("flightKey"))
+		if err != nil {
+			slog.Error("Could not read fare history", "error", err)
+			writeJSON(w, http.StatusInternalServerError, apiError{"could not read fare history"})
+			return
+		}
+		if len(observations) == 0 {
+			writeJSON(w, http.StatusNotFound, apiError{"no history of flight " + r.PathValue("flightKey")})
+			return
+		}
+		writeJSON(w, http.StatusOK, observations)
+	})
+	return mux
+}
+
+// parseSearchQuery reads a search like /search of the bot, trip length defaults to the duration arguments.
+func parseSearchQuery(query url.Values) (searchRequest, error) {
+	var request searchRequest
+	if query.Get("from") == "" || query.Get("to") == "" {
+		return request, errors.New("from and to airports are required, e.g. from=WAW,WMI&to=ALC")
+	}
+	var err error
+	if request.From, err = parseAirportCodes(query.Get("from")); err != nil {
+		return request, err
+	}
+	if request.To, err = parseAirportCodes(query.Get("to")); err != nil {
+		return request, err
+	}
+	request.MinNights, request.MaxNights = minTripDurationInDays, maxTripDurationInDays
+	for name, nights := range map[string]*int{"min": &request.MinNights, "max": &request.MaxNights} {
+		if value := query.Get(name); value != "" {
+			if *nights, err = strconv.Atoi(value); err != nil {
+				return request, fmt.Errorf("%s must be a number of nights, got: %s", name, value)
+			}
+		}
+	}
+	if request.MinNights <= 0 || request.MaxNights < request.MinNights {
+		return request, fmt.Errorf("trip length must be 0 < min <= max nights, got: %d-%d", request.MinNights, request.MaxNights)
+	}
+	if value := query.Get("month"); value != "" {
+		month, ok := parseMonthName(value)
+		if !ok {
+			return request, fmt.Errorf("unknown month %s", value)
+		}
+		request.Month = month
+	}
+	return request, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Warn("Could not write response", "error", err)
+	}
+}
+
+// runServer serves the API until interrupted, with upstream responses cached for server.cacheTTLSeconds.
+func runServer(strategy RankingStrategy) error {
+	if config.Server.CacheTTLSeconds > 0 {
+		upstreamCache = newResponseCache(time.Duration(config.Server.CacheTTLSeconds) * time.Second)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: config.Server.ListenAddr, Handler: apiHandler(strategy), ReadHeaderTimeout: serverReadHeaderTimeout}
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Serving API", "addr", config.Server.ListenAddr)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_apiHandler(t *testing.T) {
+	_, closeUpstream := fakeUpstream(t, nil)
+	defer closeUpstream()
+	defer func(previous Config) { config = previous }(config)
+	config = defaultConfig()
+	config.History.Path = filepath.Join(t.TempDir(), "history.db")
+	store, err := openHistoryStore(config.History.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fare := mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN")
+	fare.Outbound.FlightKey = "FR~1001~ ~~WMI~10/04/2024 12:00~ALC~10/04/2024 15:30~~"
+	if err := store.record([]Fare{fare}, time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	strategy, _ := newRankingStrategy(config.Ranking)
+	server := httptest.NewServer(apiHandler(strategy))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		check      func(t *testing.T, body []byte)
+	}{
+		{
+			name:       "health",
+			path:       "/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "search",
+			path:       "/search?from=wmi&to=ALC&min=5&max=9",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var response searchResponse
+				if err := json.Unmarshal(body, &response); err != nil {
+					t.Fatal(err)
+				}
+				if response.EuroRate != 4.3 || len(response.Trips) != 1 {
+					t.Fatalf("unexpected response: %s", body)
+				}
+				if trip := response.Trips[0]; trip.Route != "WMI-ALC" || trip.Nights != 6 || trip.Total != 166 {
+					t.Errorf("unexpected trip: %+v", trip)
+				}
+			},
+		},
+		{
+			name:       "search without destination",
+			path:       "/search?from=WMI",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "search with too many airports",
+			path:       "/search?from=WAW,WMI,KRK,GDN,KTW,POZ&to=ALC&min=5&max=9",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "search with reversed trip length",
+			path:       "/search?from=WMI&to=ALC&min=9&max=5",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "history",
+			path:       "/history/" + url.PathEscape(fare.Outbound.FlightKey),
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var observations []FareObservation
+				if err := json.Unmarshal(body, &observations); err != nil {
+					t.Fatal(err)
+				}
+				if len(observations) != 1 || observations[0].Price != 80 {
+					t.Errorf("unexpected history: %s", body)
+				}
+			},
+		},
+		{
+			name:       "history of unknown flight",
+			path:       "/history/FR~9999",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown path",
+			path:       "/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body json.RawMessage
+			json.NewDecoder(resp.Body).Decode(&body)
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("status, got: %d != want: %d\n%s", resp.StatusCode, test.wantStatus, body)
+			}
+			if test.check != nil {
+				test.check(t, body)
+			}
+		})
+	}
+}
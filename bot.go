@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	botRetryDelay = 5 * time.Second
+	// maxSearchAirports limits the fetches of one search to maxSearchAirports² routes each way
+	maxSearchAirports = 5
+)
+
+var iataCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type telegramUpdate struct {
+	UpdateID int              `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// searchRequest is a parsed /search command. Zero Month means the whole search horizon.
+type searchRequest struct {
+	From, To             []string
+	MinNights, MaxNights int
+	Month                time.Month
+}
+
+// bot answers commands from chats on the allowlist.
+type bot struct {
+	token     string
+	allowed   []string
+	strategy  RankingStrategy
+	formatter messageFormatter
+}
+
+// newBot takes the bot token from config or arguments, the chat given in arguments is always allowed.
+func newBot(strategy RankingStrategy) (*bot, error) {
+	token := config.Bot.Token
+	if botToken != "" {
+		token = botToken
+	}
+	if token == "" {
+		return nil, errors.New("bot needs bot.token in config file or botToken argument")
+	}
+	allowed := slices.Clone(config.Bot.AllowedChats)
+	if chatId != "" {
+		allowed = append(allowed, chatId)
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("bot needs bot.allowedChats in config file or chatId argument")
+	}
+	formatter, err := newMessageFormatter(config.Telegram.ParseMode)
+	if err != nil {
+		return nil, err
+	}
+	return &bot{token, allowed, strategy, formatter}, nil
+}
+
+func isBotRunMode(mode string) bool {
+	switch mode {
+	case botRunMode, webhookRunMode, setWebhookRunMode, deleteWebhookRunMode:
+		return true
+	}
+	return false
+}
+
+// runBotMode long-polls telegram or serves webhook updates until interrupted, or manages the webhook.
+func runBotMode(mode string, strategy RankingStrategy) error {
+	b, err := newBot(strategy)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case setWebhookRunMode:
+		return b.setWebhook()
+	case deleteWebhookRunMode:
+		return b.deleteWebhook()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if mode == webhookRunMode {
+		return b.serveWebhook(ctx)
+	}
+	if timeout := time.Duration(config.Bot.PollTimeoutSeconds) * time.Second; timeout <= 0 || timeout >= httpClient.Timeout {
+		return fmt.Errorf("bot.pollTimeoutSeconds must be between 1 and %d, got: %d", int(httpClient.Timeout.Seconds())-1, config.Bot.PollTimeoutSeconds)
+	}
+	slog.Info("Bot is polling for commands")
+	return b.poll(ctx)
+}
+
+func (b *bot) poll(ctx context.Context) error {
+	offset := 0
+	for ctx.Err() == nil {
+		next, err := b.pollOnce(offset)
+		if err != nil {
+			slog.Error("Could not get updates", "error", err)
+			telegramSleep(botRetryDelay)
+			continue
+		}
+		offset = next
+	}
+	return nil
+}
+
+// pollOnce handles updates from offset on and returns the offset of the next update.
+func (b *bot) pollOnce(offset int) (int, error) {
+	data := url.Values{}
+	data.Set("offset", strconv.Itoa(offset))
+	data.Set("timeout", strconv.Itoa(config.Bot.PollTimeoutSeconds))
+	data.Set("allowed_updates", `["message"]`)
+	result, err := postTelegramForm(b.token, "getUpdates", data)
+	if err != nil {
+		return offset, err
+	}
+	var updates []telegramUpdate
+	if err := json.Unmarshal(result, &updates); err != nil {
+		return offset, fmt.Errorf("error unmarshalling telegram updates: %v", err)
+	}
+	for _, update := range updates {
+		b.handleUpdate(update)
+		offset = update.UpdateID + 1
+	}
+	return offset, nil
+}
+
+func (b *bot) handleUpdate(update telegramUpdate) {
+	if update.Message == nil || !strings.HasPrefix(update.Message.Text, "/") {
+		return
+	}
+	chat := strconv.FormatInt(update.Message.Chat.ID, 10)
+	if !slices.Contains(b.allowed, chat) {
+		slog.Warn("Ignoring command from a chat not on the allowlist", "chat", chat)
+		return
+	}
+	reply := b.handleCommand(time.Now(), chat, update.Message.Text)
+	if err := sendMessageToTelegram(reply, b.token, chat, b.formatter.parseMode()); err != nil {
+		slog.Error("Could not reply", "chat", chat, "error", err)
+	}
+}
+
+// handleCommand routes a command to its handler and renders the reply in the language of the chat,
+// errors are replied too.
+func (b *bot) handleCommand(now time.Time, chat, text string) bytes.Buffer {
+	fields := strings.Fields(text)
+	command, _, _ := strings.Cut(strings.ToLower(fields[0]), "@")
+	language := chatLanguage(chat)
+	var reply bytes.Buffer
+	var err error
+	switch command {
+	case "/start", "/help":
+		reply, err = renderTemplate("help", language, b.formatter, nil)
+	case "/search":
+		reply, err = b.search(now, fields[1:], language)
+	case "/cheapest":
+		reply, err = b.cheapest(now, language)
+	case "/subscribe":
+		if err = subscribe(chat, fields[1:]); err == nil {
+			reply, err = renderTemplate("subscribed", language, b.formatter, nil)
+		}
+	case "/unsubscribe":
+		err = updateSubscriptions(func(subscriptions map[string]Preferences) error {
+			delete(subscriptions, chat)
+			return nil
+		})
+		if err == nil {
+			reply, err = renderTemplate("unsubscribed", language, b.formatter, nil)
+		}
+	case "/budget", "/quiet", "/language":
+		err = updatePreferences(chat, func(preferences *Preferences) error {
+			return setPreference(preferences, command, fields[1:])
+		})
+		if err == nil {
+			// /language is answered in the new language
+			reply, err = renderTemplate("preferencesSaved", chatLanguage(chat), b.formatter, nil)
+		}
+	case "/settings":
+		reply, err = b.settings(chat, language)
+	default:
+		reply, err = renderTemplate("unknownCommand", language, b.formatter, nil)
+	}
+	if err != nil {
+		slog.Warn("Command failed", "command", command, "chat", chat, "error", err)
+		var renderErr error
+		if reply, renderErr = renderTemplate("commandFailed", language, b.formatter, commandFailedData{command, err.Error()}); renderErr != nil {
+			reply.Reset()
+			reply.WriteString(b.formatter.escape(fmt.Sprintf("%s failed: %v", command, err)))
+		}
+	}
+	return reply
+}
+
+// chatLanguage is the language of a subscribed chat, empty means templates.language.
+func chatLanguage(chat string) string {
+	state, err := loadState(config.StatePath)
+	if err != nil {
+		return ""
+	}
+	return state.Subscriptions[chat].Language
+}
+
+func (b *bot) search(now time.Time, args []string, language string) (bytes.Buffer, error) {
+	request, err := parseSearchArgs(args)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	trips, _, err := searchTrips(now, request)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	rankTrips(trips, b.strategy)
+	offers := selectOffers(trips, offerLimits{total: config.Limits.OffersPerMonth}, config.Selection)
+	return renderTemplate("trips", language, b.formatter, tripsData{&request, offers})
+}
+
+func (b *bot) cheapest(now time.Time, language string) (bytes.Buffer, error) {
+	flightsToCompare, _, err := fetchFlightsToCompare(now)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	var trips []FlightToCompare
+	for _, bucketTrips := range flightsToCompare {
+		trips = append(trips, bucketTrips...)
+	}
+	strategy, err := newRankingStrategy(RankingConfig{Strategy: totalPriceStrategyName})
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	rankTrips(trips, strategy)
+	offers := selectOffers(trips, offerLimits{total: config.Limits.OffersPerMonth}, config.Selection)
+	return renderTemplate("trips", language, b.formatter, tripsData{nil, offers})
+}
+
+// parseSearchArgs parses FROM TO MIN-MAX [MONTH], airports may be comma separated, e.g. WAW,WMI ALC 5-9 may.
+func parseSearchArgs(args []string) (searchRequest, error) {
+	var request searchRequest
+	if len(args) < 3 || len(args) > 4 {
+		return request, errors.New("usage: /search FROM TO MIN-MAX [MONTH], e.g. /search WAW ALC 5-9 may")
+	}
+	var err error
+	if request.From, err = parseAirportCodes(args[0]); err != nil {
+		return request, err
+	}
+	if request.To, err = parseAirportCodes(args[1]); err != nil {
+		return request, err
+	}
+	min, max, ok := strings.Cut(args[2], "-")
+	if !ok {
+		return request, fmt.Errorf("trip length must be MIN-MAX nights, got: %s", args[2])
+	}
+	request.MinNights, err = strconv.Atoi(min)
+	if err != nil {
+		return request, fmt.Errorf("trip length must be MIN-MAX nights, got: %s", args[2])
+	}
+	request.MaxNights, err = strconv.Atoi(max)
+	if err != nil {
+		return request, fmt.Errorf("trip length must be MIN-MAX nights, got: %s", args[2])
+	}
+	if request.MinNights <= 0 || request.MaxNights < request.MinNights {
+		return request, fmt.Errorf("trip length must be MIN-MAX nights with 0 < MIN <= MAX, got: %s", args[2])
+	}
+	if len(args) == 4 {
+		month, ok := parseMonthName(args[3])
+		if !ok {
+			return request, fmt.Errorf("unknown month %s", args[3])
+		}
+		request.Month = month
+	}
+	return request, nil
+}
+
+// parseAirportCodes parses at most maxSearchAirports comma separated codes, every pair of airports
+// of a search is fetched separately.
+func parseAirportCodes(arg string) ([]string, error) {
+	codes := strings.Split(strings.ToUpper(arg), ",")
+	if len(codes) > maxSearchAirports {
+		return nil, fmt.Errorf("at most %d airports on each side of a search, got: %d", maxSearchAirports, len(codes))
+	}
+	for _, code := range codes {
+		if !iataCodePattern.MatchString(code) {
+			return nil, fmt.Errorf("airport must be a 3 letter IATA code, got: %s", code)
+		}
+	}
+	return codes, nil
+}
+
+// searchTrips fetches fares of the request routes in both directions and pairs them into trips priced in PLN,
+// converted with the euro rate it returns.
+// A month in the past refers to the next year.
+func searchTrips(now time.Time, request searchRequest) ([]FlightToCompare, float64, error) {
+	startDate, endDate := searchHorizon(now)
+	if request.Month != 0 {
+		startDate = time.Date(now.Year(), request.Month, 1, 0, 0, 0, 0, now.Location())
+		if request.Month < now.Month() {
+			startDate = startDate.AddDate(1, 0, 0)
+		}
+		endDate = startDate.AddDate(0, 1, -1)
+	}
+	outboundFares, err := getFares(request.From, request.To, startDate, endDate)
+	if err != nil {
+		return nil, 0, err
+	}
+	returnFares, err := getFares(request.To, request.From, startDate, endDate.AddDate(0, 0, request.MaxNights))
+	if err != nil {
+		return nil, 0, err
+	}
+	euroRate, err := getEuroRate()
+	if err != nil {
+		return nil, 0, err
+	}
+	convertFaresToPLN(outboundFares, euroRate)
+	convertFaresToPLN(returnFares, euroRate)
+
+	flightsToCompare, err := pairNights(outboundFares, returnFares, request.MinNights, request.MaxNights)
+	if err != nil {
+		return nil, 0, err
+	}
+	var trips []FlightToCompare
+	for _, bucketTrips := range flightsToCompare {
+		for _, trip := range bucketTrips {
+			if departure, err := trip.AbroadFlight.departureTime(); err != nil ||
+				(request.Month != 0 && departure.Month() != request.Month) {
+				continue
+			}
+			trips = append(trips, trip)
+		}
+	}
+	return trips, euroRate, nil
+}
+
+// convertFaresToPLN converts fares priced in EUR, fares from polish airports already are in PLN.
+// The price before conversion is kept as OriginalPrice.
+func convertFaresToPLN(fares []Fare, euroRate float64) {
+	for i := range fares {
+		original := fares[i].Outbound.Price
+		fares[i].Outbound.OriginalPrice = &original
+		if fares[i].Outbound.Price.CurrencyCode == "EUR" {
+			fare := fares[i : i+1]
+			convertEURtoPLN(&fare, euroRate)
+		}
+	}
+}
+
+// subscribe adds the chat to subscriptions with routes and nights given as in /search,
+// other preferences of an already subscribed chat are kept.
+func subscribe(chat string, args []string) error {
+	var routes []string
+	var request searchRequest
+	if len(args) > 0 {
+		var err error
+		request, err = parseSearchArgs(args)
+		if err != nil {
+			return err
+		}
+		if request.Month != 0 {
+			return errors.New("subscriptions cover the whole search horizon, remove the month")
+		}
+		for _, from := range request.From {
+			for _, to := range request.To {
+				routes = append(routes, from+"-"+to)
+			}
+		}
+	}
+	return updateSubscriptions(func(subscriptions map[string]Preferences) error {
+		preferences := subscriptions[chat]
+		preferences.Routes, preferences.MinNights, preferences.MaxNights = routes, request.MinNights, request.MaxNights
+		if err := validatePreferences(preferences); err != nil {
+			return err
+		}
+		subscriptions[chat] = preferences
+		return nil
+	})
+}
+
+// setPreference applies /budget, /quiet or /language arguments.
+func setPreference(preferences *Preferences, command string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing value, see /help")
+	}
+	switch command {
+	case "/budget":
+		if strings.EqualFold(args[0], "off") {
+			preferences.MaxTotal = 0
+			return nil
+		}
+		budget, err := strconv.ParseFloat(strings.Replace(args[0], ",", ".", 1), 64)
+		if err != nil || budget <= 0 {
+			return fmt.Errorf("budget must be a positive amount, got: %s", args[0])
+		}
+		preferences.MaxTotal = budget
+	case "/quiet":
+		if strings.EqualFold(args[0], "off") {
+			preferences.QuietHours = nil
+			return nil
+		}
+		from, to, ok := strings.Cut(args[0], "-")
+		if !ok {
+			return fmt.Errorf("quiet hours must be like 22:00-07:00, got: %s", args[0])
+		}
+		preferences.QuietHours = &QuietHours{From: from, To: to}
+		if len(args) > 1 {
+			preferences.QuietHours.Timezone = args[1]
+		}
+	case "/language":
+		preferences.Language = strings.ToLower(args[0])
+	}
+	return nil
+}
+
+func (b *bot) settings(chat, language string) (bytes.Buffer, error) {
+	state, err := loadState(config.StatePath)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	preferences, ok := state.Subscriptions[chat]
+	if !ok {
+		return renderTemplate("notSubscribed", language, b.formatter, nil)
+	}
+	// the reply shows what digests use in place of zero values
+	preferences.Routes = preferences.routes()
+	if preferences.MinNights == 0 && preferences.MaxNights == 0 {
+		preferences.MinNights = minTripDurationInDays
+	}
+	if preferences.MaxNights == 0 {
+		preferences.MaxNights = maxTripDurationInDays
+	}
+	if preferences.Language == "" {
+		preferences.Language = config.Templates.Language
+	}
+	return renderTemplate("settings", language, b.formatter, preferences)
+}
+
+// subscriberBotToken is the bot token given in arguments or bot.token from config.
+func subscriberBotToken() string {
+	if botToken != "" {
+		return botToken
+	}
+	return config.Bot.Token
+}
+
+// hasSubscribers is true when chats subscribed through the bot and there is a bot token to notify them.
+func hasSubscribers(state State) bool {
+	return subscriberBotToken() != "" && len(state.Subscriptions) > 0
+}
+
+// subscriberTargets are telegram targets for chats subscribed through the bot and not configured already.
+func subscriberTargets(state State, existing []target) []target {
+	if !hasSubscribers(state) {
+		return nil
+	}
+	token := subscriberBotToken()
+	formatter, err := newMessageFormatter(config.Telegram.ParseMode)
+	if err != nil {
+		slog.Error("Could not notify subscribers", "error", err)
+		return nil
+	}
+	var chats []string
+	for chat := range state.Subscriptions {
+		addLogSecrets(chat)
+		chats = append(chats, chat)
+	}
+	slices.Sort(chats)
+	var targets []target
+	for _, chat := range chats {
+		if slices.ContainsFunc(existing, func(t target) bool {
+			telegram, ok := t.notifier.(telegramNotifier)
+			return ok && telegram.chatId == chat
+		}) {
+			continue
+		}
+		targets = append(targets, target{chat, telegramNotifier{token, chat, formatter.parseMode()}, state.Subscriptions[chat], formatter})
+	}
+	return targets
+}
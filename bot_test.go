@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseSearchArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    searchRequest
+		wantErr bool
+	}{
+		{
+			name: "single airports with month",
+			args: "WAW ALC 5-9 may",
+			want: searchRequest{[]string{"WAW"}, []string{"ALC"}, 5, 9, time.May},
+		},
+		{
+			name: "comma separated lowercase airports without month",
+			args: "waw,wmi ALC 3-15",
+			want: searchRequest{[]string{"WAW", "WMI"}, []string{"ALC"}, 3, 15, 0},
+		},
+		{
+			name:    "missing trip length",
+			args:    "WAW ALC",
+			wantErr: true,
+		},
+		{
+			name:    "reversed trip length",
+			args:    "WAW ALC 9-5",
+			wantErr: true,
+		},
+		{
+			name:    "airport name instead of code",
+			args:    "Warsaw ALC 5-9",
+			wantErr: true,
+		},
+		{
+			name:    "unknown month",
+			args:    "WAW ALC 5-9 maj",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseSearchArgs(strings.Fields(test.args))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got: %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got.From, test.want.From) || !slices.Equal(got.To, test.want.To) ||
+				got.MinNights != test.want.MinNights || got.MaxNights != test.want.MaxNights || got.Month != test.want.Month {
+				t.Errorf("got: %+v != want: %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// fakeUpstream serves telegram bot API, ryanair fares and the NBP euro rate for bot tests.
+// Telegram replies are recorded per chat.
+func fakeUpstream(t *testing.T, updates []telegramUpdate) (map[string][]string, func()) {
+	replies := make(map[string][]string)
+	fares := map[string][]Fare{
+		"WMI": {mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN")},
+		"ALC": {
+			mockFare("ALC", "WMI", "2024-10-10T12:00:00", 20, "EUR"),
+			mockFare("ALC", "WMI", "2024-10-20T12:00:00", 10, "EUR"),
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/bottoken/getUpdates":
+			result, _ := json.Marshal(updates)
+			fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+		case r.URL.Path == "/bottoken/sendMessage":
+			replies[r.FormValue("chat_id")] = append(replies[r.FormValue("chat_id")], r.FormValue("text"))
+			fmt.Fprint(w, `{"ok":true,"result":{}}`)
+		case r.URL.Path == "/api/farfnd/v4/oneWayFares":
+			json.NewEncoder(w).Encode(FlightResponse{Fares: fares[r.URL.Query().Get("departureAirportIataCode")]})
+		case strings.HasPrefix(r.URL.Path, "/api/exchangerates/"):
+			fmt.Fprint(w, `{"rates":[{"mid":4.3}]}`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	}))
+	telegramAPIURL, ryanairAPIURL, nbpAPIURL = server.URL, server.URL, server.URL
+	return replies, func() {
+		server.Close()
+		telegramAPIURL = "https://api.telegram.org"
+		ryanairAPIURL = "https://www.ryanair.com"
+		nbpAPIURL = "https://api.nbp.pl"
+	}
+}
+
+func mockFare(from, to, departureDate string, price float64, currency string) Fare {
+	return Fare{Outbound: Outbound{
+		DepartureAirport: Airport{IATACode: from, Name: from},
+		ArrivalAirport:   Airport{IATACode: to, Name: to},
+		DepartureDate:    departureDate,
+		Price:            Price{Value: price, CurrencyCode: currency},
+		FlightKey:        from + "~" + departureDate + "~" + to,
+		FlightNumber:     "FR" + from,
+	}}
+}
+
+func newTelegramUpdate(id int, chat int64, text string) telegramUpdate {
+	update := telegramUpdate{UpdateID: id, Message: &telegramMessage{Text: text}}
+	update.Message.Chat.ID = chat
+	return update
+}
+
+func Test_bot_pollOnce(t *testing.T) {
+	replies, closeUpstream := fakeUpstream(t, []telegramUpdate{
+		newTelegramUpdate(10, 123, "/search WMI ALC 5-9 oct"),
+		newTelegramUpdate(11, 999, "/search WMI ALC 5-9 oct"),
+		newTelegramUpdate(12, 123, "/subscribe@ryan_bot"),
+		newTelegramUpdate(13, 123, "hello"),
+		newTelegramUpdate(14, 123, "/book"),
+	})
+	defer closeUpstream()
+	defer func(previous Config) { config = previous }(config)
+	config = defaultConfig()
+	config.StatePath = filepath.Join(t.TempDir(), "state.json")
+	config.Telegram.ParseMode = plainParseMode
+
+	strategy, _ := newRankingStrategy(config.Ranking)
+	b := &bot{"token", []string{"123"}, strategy, plainFormatter{}}
+	offset, err := b.pollOnce(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 15 {
+		t.Errorf("next offset, got: %d != want: %d", offset, 15)
+	}
+	if len(replies["999"]) != 0 {
+		t.Errorf("chat not on allowlist got replies: %v", replies["999"])
+	}
+	got := replies["123"]
+	if len(got) != 3 {
+		t.Fatalf("replies, got: %d != want: %d\n%v", len(got), 3, got)
+	}
+	if !strings.Contains(got[0], "WMI ---> ALC 2024-10-04 12:00 pt. 80,00 PLN") || !strings.Contains(got[0], "Razem: 166,00 zł") {
+		t.Errorf("search reply does not contain the 6 nights trip:\n%s", got[0])
+	}
+	if strings.Contains(got[0], "2024-10-20") {
+		t.Errorf("search reply contains trip longer than 9 nights:\n%s", got[0])
+	}
+	if !strings.HasPrefix(got[1], "Subskrypcja zapisana") || !strings.HasPrefix(got[2], "Nieznana komenda") {
+		t.Errorf("unexpected replies: %v", got[1:])
+	}
+	state, err := loadState(config.StatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Subscriptions["123"]; !ok || len(state.Subscriptions) != 1 {
+		t.Errorf("subscriptions, got: %v", state.Subscriptions)
+	}
+}
+
+func Test_bot_handleCommand_language(t *testing.T) {
+	defer func(previous Config) { config = previous }(config)
+	config = defaultConfig()
+	config.StatePath = filepath.Join(t.TempDir(), "state.json")
+	b := &bot{"token", []string{"123"}, totalPriceStrategy{}, plainFormatter{}}
+	now := time.Date(2024, time.October, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"/settings", "Ten czat nie ma subskrypcji"},
+		{"/subscribe WMI ALC 3-7", "Subskrypcja zapisana"},
+		{"/budget 1350", "Ustawienia zapisane"},
+		{"/settings", "Trasy: WMI-ALC\nNoce: 3-7\nBudżet: 1 350,00 zł\nJęzyk: pl\n"},
+		{"/language en", "Preferences saved"},
+		{"/settings", "Routes: WMI-ALC\nNights: 3-7\nBudget: 1,350.00 zł\nLanguage: en\nQuiet hours: none"},
+		{"/budget -1", "/budget failed: budget must be a positive amount"},
+	}
+	for _, test := range tests {
+		reply := b.handleCommand(now, "123", test.command)
+		if !strings.Contains(reply.String(), test.want) {
+			t.Errorf("%s, reply does not contain %q:\n%s", test.command, test.want, reply.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	departureMonthBucketing = "departure-month"
+	returnMonthBucketing    = "return-month"
+	isoWeekBucketing        = "iso-week"
+)
+
+// Bucket groups trips in the report. Monthly buckets have Month set, weekly buckets have Week set.
+type Bucket struct {
+	Year  int
+	Month time.Month
+	Week  int
+}
+
+func validateBucketing(bucketing string) error {
+	switch bucketing {
+	case "", departureMonthBucketing, returnMonthBucketing, isoWeekBucketing:
+		return nil
+	default:
+		return fmt.Errorf("unknown bucketing: %s. available bucketing: %s, %s, %s",
+			bucketing,
+			departureMonthBucketing,
+			returnMonthBucketing,
+			isoWeekBucketing,
+		)
+	}
+}
+
+func tripBucket(departureDate, returnDate time.Time, bucketing string) Bucket {
+	switch bucketing {
+	case returnMonthBucketing:
+		return Bucket{Year: returnDate.Year(), Month: returnDate.Month()}
+	case isoWeekBucketing:
+		year, week := departureDate.ISOWeek()
+		return Bucket{Year: year, Week: week}
+	default:
+		return Bucket{Year: departureDate.Year(), Month: departureDate.Month()}
+	}
+}
+
+// horizonBuckets lists every bucket between startDate and endDate in chronological order.
+func horizonBuckets(startDate, endDate time.Time, bucketing string) []Bucket {
+	var buckets []Bucket
+	first := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, startDate.Location())
+	step := func(date time.Time) time.Time { return date.AddDate(0, 1, 0) }
+	if bucketing == isoWeekBucketing {
+		first = startDate
+		step = func(date time.Time) time.Time { return date.AddDate(0, 0, 7) }
+	}
+	for date := first; !date.After(endDate); date = step(date) {
+		buckets = append(buckets, tripBucket(date, date, bucketing))
+	}
+	if last := tripBucket(endDate, endDate, bucketing); len(buckets) == 0 || buckets[len(buckets)-1] != last {
+		buckets = append(buckets, last)
+	}
+	return buckets
+}
+
+// reportBuckets merges horizon buckets with buckets of found trips, e.g. returns after the horizon end.
+func reportBuckets(startDate, endDate time.Time, flightsToCompare map[Bucket][]FlightToCompare) []Bucket {
+	buckets := horizonBuckets(startDate, endDate, config.Bucketing)
+	seen := make(map[Bucket]bool, len(buckets))
+	for _, bucket := range buckets {
+		seen[bucket] = true
+	}
+	for bucket := range flightsToCompare {
+		if !seen[bucket] {
+			buckets = append(buckets, bucket)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].before(buckets[j]) })
+	return buckets
+}
+
+func (b Bucket) before(other Bucket) bool {
+	if b.Year != other.Year {
+		return b.Year < other.Year
+	}
+	if b.Month != other.Month {
+		return b.Month < other.Month
+	}
+	return b.Week < other.Week
+}
+
+// String returns the bucket key used in config, e.g. 2024-05 or 2024-W07.
+func (b Bucket) String() string {
+	if b.Week > 0 {
+		return fmt.Sprintf("%d-W%02d", b.Year, b.Week)
+	}
+	return fmt.Sprintf("%d-%02d", b.Year, int(b.Month))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_tripBucket(t *testing.T) {
+	departureDate := time.Date(2025, time.February, 28, 10, 0, 0, 0, time.UTC)
+	returnDate := time.Date(2025, time.March, 10, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		bucketing string
+		want      string
+	}{
+		{
+			name:      "bucket by departure month",
+			bucketing: departureMonthBucketing,
+			want:      "2025-02",
+		},
+		{
+			name:      "bucket by return month",
+			bucketing: returnMonthBucketing,
+			want:      "2025-03",
+		},
+		{
+			name:      "bucket by iso week",
+			bucketing: isoWeekBucketing,
+			want:      "2025-W09",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := tripBucket(departureDate, returnDate, test.bucketing)
+			if got.String() != test.want {
+				t.Errorf("bucket, got: %s != want: %s", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_horizonBuckets(t *testing.T) {
+	startDate := time.Date(2024, time.November, 1, 0, 0, 0, 0, time.UTC)
+	endDate := startDate.AddDate(0, 14, -1)
+	buckets := horizonBuckets(startDate, endDate, departureMonthBucketing)
+	if len(buckets) != 14 {
+		t.Fatalf("horizon buckets length, got: %d != want: %d", len(buckets), 14)
+	}
+	if buckets[0].String() != "2024-11" || buckets[12].String() != "2025-11" {
+		t.Errorf("months from different years collide, got: %s and %s", buckets[0], buckets[12])
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// upstreamCache keeps ryanair and NBP responses in the server mode, nil disables caching.
+var upstreamCache *responseCache
+
+// responseCache keeps raw upstream responses for a time to live. Raw bodies are cached,
+// so every caller unmarshals fares of its own and may convert them in place.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]cacheEntry
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, now: time.Now, entries: make(map[string]cacheEntry)}
+}
+
+// get returns the cached body of key or fetches and caches it. Failed fetches are not cached.
+// A nil cache always fetches.
+func (c *responseCache) get(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return fetch()
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.now().Before(entry.expires) {
+		c.hits++
+		c.mu.Unlock()
+		cacheRequests.add(1, "hit")
+		return entry.body, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+	cacheRequests.add(1, "miss")
+
+	body, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[key] = cacheEntry{body, now.Add(c.ttl)}
+	return body, nil
+}
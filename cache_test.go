@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_responseCache_get(t *testing.T) {
+	clock := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	cache := newResponseCache(time.Minute)
+	cache.now = func() time.Time { return clock }
+	fetches := 0
+	fetch := func() ([]byte, error) {
+		fetches++
+		return []byte("fares"), nil
+	}
+
+	for _, advance := range []time.Duration{0, 30 * time.Second, 31 * time.Second} {
+		clock = clock.Add(advance)
+		body, err := cache.get("WMI-ALC", fetch)
+		if err != nil || string(body) != "fares" {
+			t.Fatalf("got: %q, %v", body, err)
+		}
+	}
+	if fetches != 2 || cache.hits != 1 || cache.misses != 2 {
+		t.Errorf("fetches: %d, hits: %d, misses: %d, want the second call cached only", fetches, cache.hits, cache.misses)
+	}
+
+	if _, err := cache.get("ALC-WMI", func() ([]byte, error) { return nil, errors.New("unavailable") }); err == nil {
+		t.Error("expected fetch error")
+	}
+	if _, ok := cache.entries["ALC-WMI"]; ok {
+		t.Error("failed fetch was cached")
+	}
+
+	var disabled *responseCache
+	disabled.get("WMI-ALC", fetch)
+	if fetches != 3 {
+		t.Error("nil cache did not fetch")
+	}
+}
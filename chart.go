@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth        = 800
+	chartHeight       = 400
+	chartMarginLeft   = 70
+	chartMarginRight  = 30
+	chartMarginTop    = 40
+	chartMarginBottom = 40
+	chartTicks        = 5
+	chartDateLayout   = "01-02"
+
+	// glyphs are 3x5 pixels drawn glyphScale times larger
+	glyphWidth, glyphHeight = 3, 5
+	glyphScale              = 2
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartGrid       = color.RGBA{225, 225, 225, 255}
+	chartAxis       = color.RGBA{90, 90, 90, 255}
+	chartText       = color.RGBA{34, 34, 34, 255}
+	chartLine       = color.RGBA{7, 53, 144, 255}
+)
+
+// glyphs is a minimal bitmap font, enough for airport codes, flight numbers, dates and prices.
+var glyphs = map[rune][glyphHeight]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'A': {".#.", "#.#", "###", "#.#", "#.#"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {".##", "#..", "#..", "#..", ".##"},
+	'D': {"##.", "#.#", "#.#", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'F': {"###", "#..", "##.", "#..", "#.."},
+	'G': {".##", "#..", "#.#", "#.#", ".##"},
+	'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"},
+	'J': {"..#", "..#", "..#", "#.#", ".#."},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+	'N': {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O': {".#.", "#.#", "#.#", "#.#", ".#."},
+	'P': {"##.", "#.#", "##.", "#..", "#.."},
+	'Q': {".#.", "#.#", "#.#", "##.", ".##"},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {".##", "#..", ".#.", "..#", "##."},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W': {"#.#", "#.#", "###", "###", "#.#"},
+	'X': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y': {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z': {"###", "..#", ".#.", "#..", "###"},
+	'-': {"...", "...", "###", "...", "..."},
+	'.': {"...", "...", "...", "...", ".#."},
+	',': {"...", "...", "...", ".#.", "#.."},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'>': {"#..", ".#.", "..#", ".#.", "#.."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	'(': {".#.", "#..", "#..", "#..", ".#."},
+	')': {".#.", "..#", "..#", "..#", ".#."},
+}
+
+type chartPoint struct {
+	X time.Time
+	Y float64
+}
+
+// lineChart plots prices over time, points are joined in the order of X.
+type lineChart struct {
+	Title  string
+	Points []chartPoint
+}
+
+// chartImage is a rendered chart with the caption it is sent with.
+type chartImage struct {
+	Caption string
+	PNG     []byte
+}
+
+// render draws the chart as PNG with price gridlines on the left and dates at the bottom.
+func (c lineChart) render() (chartImage, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+	drawText(img, chartMarginLeft, (chartMarginTop-glyphHeight*glyphScale)/2, c.Title, chartText)
+
+	left, right := chartMarginLeft, chartWidth-chartMarginRight
+	top, bottom := chartMarginTop, chartHeight-chartMarginBottom
+	points := append([]chartPoint(nil), c.Points...)
+	sort.Slice(points, func(i, j int) bool { return points[i].X.Before(points[j].X) })
+	if len(points) == 0 {
+		drawText(img, (left+right-textWidth("NO DATA"))/2, (top+bottom)/2, "NO DATA", chartText)
+	} else {
+		minY, maxY := math.Inf(1), math.Inf(-1)
+		for _, point := range points {
+			minY, maxY = math.Min(minY, point.Y), math.Max(maxY, point.Y)
+		}
+		padding := math.Max((maxY-minY)*0.05, 1)
+		minY, maxY = math.Max(minY-padding, 0), maxY+padding
+		minX, maxX := points[0].X, points[len(points)-1].X
+		if !maxX.After(minX) {
+			minX, maxX = minX.Add(-12*time.Hour), maxX.Add(12*time.Hour)
+		}
+		x := func(t time.Time) int {
+			return left + int(float64(t.Sub(minX))/float64(maxX.Sub(minX))*float64(right-left))
+		}
+		y := func(value float64) int {
+			return bottom - int((value-minY)/(maxY-minY)*float64(bottom-top))
+		}
+		for i := 0; i <= chartTicks; i++ {
+			value := minY + float64(i)*(maxY-minY)/chartTicks
+			label := strconv.Itoa(int(math.Round(value)))
+			drawLine(img, left, y(value), right, y(value), chartGrid)
+			drawText(img, left-textWidth(label)-8, y(value)-glyphHeight*glyphScale/2, label, chartText)
+
+			at := minX.Add(time.Duration(i) * maxX.Sub(minX) / chartTicks)
+			label = at.Format(chartDateLayout)
+			drawLine(img, x(at), bottom, x(at), bottom+4, chartAxis)
+			drawText(img, x(at)-textWidth(label)/2, bottom+10, label, chartText)
+		}
+		for i, point := range points {
+			if i > 0 {
+				previous := points[i-1]
+				drawLine(img, x(previous.X), y(previous.Y), x(point.X), y(point.Y), chartLine)
+				drawLine(img, x(previous.X), y(previous.Y)+1, x(point.X), y(point.Y)+1, chartLine)
+			}
+			fillRect(img, x(point.X)-2, y(point.Y)-2, 5, 5, chartLine)
+		}
+	}
+	drawLine(img, left, top, left, bottom, chartAxis)
+	drawLine(img, left, bottom, right, bottom, chartAxis)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return chartImage{}, fmt.Errorf("could not encode chart: %v", err)
+	}
+	return chartImage{c.Title, encoded.Bytes()}, nil
+}
+
+// drawText writes text in upper case with the bitmap font, characters without glyph are blank.
+func drawText(img *image.RGBA, x, y int, text string, c color.Color) {
+	text = strings.ToUpper(strings.ReplaceAll(text, "→", "->"))
+	for _, r := range text {
+		glyph := glyphs[r]
+		for row, pixels := range glyph {
+			for column, pixel := range pixels {
+				if pixel == '#' {
+					fillRect(img, x+column*glyphScale, y+row*glyphScale, glyphScale, glyphScale, c)
+				}
+			}
+		}
+		x += (glyphWidth + 1) * glyphScale
+	}
+}
+
+func textWidth(text string) int {
+	characters := len([]rune(strings.ReplaceAll(text, "→", "->")))
+	return characters*(glyphWidth+1)*glyphScale - glyphScale
+}
+
+func fillRect(img *image.RGBA, x, y, width, height int, c color.Color) {
+	draw.Draw(img, image.Rect(x, y, x+width, y+height), &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// drawLine draws a one pixel line with Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// priceCharts plot the cheapest fare of every departure day in each direction of the preferences.
+func priceCharts(preferences Preferences, fares legFares) []lineChart {
+	var charts []lineChart
+	for _, direction := range preferences.directions(fares) {
+		cheapest := make(map[time.Time]float64)
+		for _, fare := range direction.fares {
+			departure, err := fare.Outbound.departureTime()
+			if err != nil {
+				continue
+			}
+			day := time.Date(departure.Year(), departure.Month(), departure.Day(), 0, 0, 0, 0, time.UTC)
+			if price, ok := cheapest[day]; !ok || fare.Outbound.Price.Value < price {
+				cheapest[day] = fare.Outbound.Price.Value
+			}
+		}
+		chart := lineChart{Title: direction.name + " PLN"}
+		for day, price := range cheapest {
+			chart.Points = append(chart.Points, chartPoint{day, price})
+		}
+		charts = append(charts, chart)
+	}
+	return charts
+}
+
+// tripTrendChart plots the total price of the trip at every fetch both of its flights were observed at.
+// Observations in the currency ryanair quoted are converted with the rate the trip was converted with.
+func tripTrendChart(store *historyStore, trip FlightToCompare) (lineChart, error) {
+	chart := lineChart{Title: fmt.Sprintf("%s %s / %s %s PLN",
+		trip.AbroadFlight.FlightNumber, trip.AbroadFlight.DepartureDate[:min(10, len(trip.AbroadFlight.DepartureDate))],
+		trip.ReturnFlight.FlightNumber, trip.ReturnFlight.DepartureDate[:min(10, len(trip.ReturnFlight.DepartureDate))],
+	)}
+	outbound, err := store.flightHistory(trip.AbroadFlight.FlightKey)
+	if err != nil {
+		return chart, err
+	}
+	inbound, err := store.flightHistory(trip.ReturnFlight.FlightKey)
+	if err != nil {
+		return chart, err
+	}
+	returnPrices := make(map[int64]float64)
+	for _, observation := range inbound {
+		returnPrices[observation.FetchedAt.UnixNano()] = observedPrice(observation, trip.ReturnFlight)
+	}
+	for _, observation := range outbound {
+		if returnPrice, ok := returnPrices[observation.FetchedAt.UnixNano()]; ok {
+			total := math.Round((observedPrice(observation, trip.AbroadFlight)+returnPrice)*100) / 100
+			chart.Points = append(chart.Points, chartPoint{observation.FetchedAt, total})
+		}
+	}
+	return chart, nil
+}
+
+func observedPrice(observation FareObservation, flight Outbound) float64 {
+	original := flight.OriginalPrice
+	if observation.Currency != flight.Price.CurrencyCode && original != nil &&
+		original.CurrencyCode == observation.Currency && original.Value > 0 {
+		return observation.Price * flight.Price.Value / original.Value
+	}
+	return observation.Price
+}
+
+// digestCharts renders price charts of the preferences and, with the history store, the trend of the best offer.
+func digestCharts(preferences Preferences, fares legFares, offers []FlightToCompare) ([]chartImage, error) {
+	charts := priceCharts(preferences, fares)
+	if config.History.Path != "" && len(offers) > 0 {
+		store, err := openHistoryStore(config.History.Path)
+		if err != nil {
+			return nil, err
+		}
+		trend, err := tripTrendChart(store, offers[0])
+		store.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(trend.Points) > 0 {
+			charts = append(charts, trend)
+		}
+	}
+	var images []chartImage
+	for _, chart := range charts {
+		rendered, err := chart.render()
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, rendered)
+	}
+	return images, nil
+}
+
+func sendDigestCharts(notifier photoNotifier, preferences Preferences, fares legFares, offers []FlightToCompare) error {
+	photos, err := digestCharts(preferences, fares, offers)
+	if err != nil {
+		return err
+	}
+	return notifier.NotifyPhotos(photos)
+}
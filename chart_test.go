@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_lineChart_render(t *testing.T) {
+	chart := lineChart{"WMI, WAW → ALC PLN", []chartPoint{
+		{time.Date(2024, 10, 10, 0, 0, 0, 0, time.UTC), 120},
+		{time.Date(2024, 10, 4, 0, 0, 0, 0, time.UTC), 80},
+		{time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC), 95.5},
+	}}
+	rendered, err := chart.render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Caption != chart.Title {
+		t.Errorf("caption, got: %s != want: %s", rendered.Caption, chart.Title)
+	}
+	img, err := png.Decode(bytes.NewReader(rendered.PNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != chartWidth || img.Bounds().Dy() != chartHeight {
+		t.Errorf("size, got: %v", img.Bounds())
+	}
+	// the earliest point is drawn at the left edge of the plot, the cheapest near its bottom
+	lineY := -1
+	for y := chartMarginTop; y < chartHeight-chartMarginBottom; y++ {
+		if img.At(chartMarginLeft+2, y) == img.ColorModel().Convert(chartLine) {
+			lineY = y
+		}
+	}
+	if lineY < chartHeight-chartMarginBottom-30 {
+		t.Errorf("cheapest point is not at the bottom left of the plot, line at y: %d", lineY)
+	}
+
+	empty, err := lineChart{Title: "ALC → WMI PLN"}.render()
+	if err != nil || len(empty.PNG) == 0 {
+		t.Errorf("chart without points was not rendered: %v", err)
+	}
+}
+
+func Test_glyphs(t *testing.T) {
+	for r, glyph := range glyphs {
+		for _, row := range glyph {
+			if len(row) != glyphWidth {
+				t.Errorf("glyph %q has row %q wider than %d", r, row, glyphWidth)
+			}
+		}
+	}
+}
+
+func Test_priceCharts(t *testing.T) {
+	fares := legFares{
+		{"WMI", "ALC"}: {
+			mockFare("WMI", "ALC", "2024-10-04T06:00:00", 80, "PLN"),
+			mockFare("WMI", "ALC", "2024-10-05T06:00:00", 90, "PLN"),
+		},
+		{"WAW", "ALC"}: {mockFare("WAW", "ALC", "2024-10-04T18:00:00", 60, "PLN")},
+		{"ALC", "WMI"}: {mockFare("ALC", "WMI", "2024-10-10T12:00:00", 86, "PLN")},
+	}
+	charts := priceCharts(Preferences{Routes: []string{"WMI-ALC", "WAW-ALC"}}, fares)
+	if len(charts) != 2 || charts[0].Title != "WMI, WAW → ALC PLN" || charts[1].Title != "ALC → WMI, WAW PLN" {
+		t.Fatalf("unexpected charts: %+v", charts)
+	}
+	cheapest := make(map[string]float64)
+	for _, point := range charts[0].Points {
+		cheapest[point.X.Format("2006-01-02")] = point.Y
+	}
+	if len(cheapest) != 2 || cheapest["2024-10-04"] != 60 || cheapest["2024-10-05"] != 90 {
+		t.Errorf("cheapest outbound per day, got: %v", cheapest)
+	}
+}
+
+func Test_tripTrendChart(t *testing.T) {
+	store, err := openHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	first := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+	outbound := mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN")
+	inbound := mockFare("ALC", "WMI", "2024-10-10T12:00:00", 20, "EUR")
+	for _, observation := range []struct {
+		fare    Fare
+		price   float64
+		fetched time.Time
+	}{
+		{outbound, 100, first},
+		{inbound, 25, first},
+		{outbound, 80, second},
+		{inbound, 20, second},
+		// the return flight was not fetched then
+		{outbound, 70, second.Add(time.Hour)},
+	} {
+		observation.fare.Outbound.Price.Value = observation.price
+		if err := store.record([]Fare{observation.fare}, observation.fetched); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fares := []Fare{inbound}
+	convertFaresToPLN(fares, 4.3)
+
+	chart, err := tripTrendChart(store, FlightToCompare{outbound.Outbound, fares[0].Outbound})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []chartPoint{{first, 207.5}, {second, 166}}
+	if len(chart.Points) != len(want) {
+		t.Fatalf("points, got: %+v != want: %+v", chart.Points, want)
+	}
+	for i := range want {
+		if !chart.Points[i].X.Equal(want[i].X) || chart.Points[i].Y != want[i].Y {
+			t.Errorf("point %d, got: %+v != want: %+v", i, chart.Points[i], want[i])
+		}
+	}
+}
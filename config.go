@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	LookForwardInMonths int               `json:"lookForwardInMonths"`
+	Bucketing           string            `json:"bucketing"`
+	Ranking             RankingConfig     `json:"ranking"`
+	Selection           SelectionConfig   `json:"selection"`
+	Limits              LimitsConfig      `json:"limits"`
+	History             HistoryConfig     `json:"history"`
+	Alerts              AlertsConfig      `json:"alerts"`
+	Thresholds          ThresholdsConfig  `json:"thresholds"`
+	Dedupe              DedupeConfig      `json:"dedupe"`
+	StatePath           string            `json:"statePath"`
+	Targets             []TargetConfig    `json:"targets"`
+	Telegram            TelegramConfig    `json:"telegram"`
+	Bot                 BotConfig         `json:"bot"`
+	Templates           TemplatesConfig   `json:"templates"`
+	Charts              ChartsConfig      `json:"charts"`
+	Attachments         AttachmentsConfig `json:"attachments"`
+	Daemon              DaemonConfig      `json:"daemon"`
+	Server              ServerConfig      `json:"server"`
+	Metrics             MetricsConfig     `json:"metrics"`
+}
+
+type RankingConfig struct {
+	Strategy      string         `json:"strategy"`
+	RedEyePenalty float64        `json:"redEyePenalty"`
+	WeekendBonus  float64        `json:"weekendBonus"`
+	Weights       RankingWeights `json:"weights"`
+}
+
+// RankingWeights are multiplied by the matching trip feature and summed, lower score wins.
+// Use negative weights to prefer a feature, e.g. weekend departures.
+type RankingWeights struct {
+	Price         float64 `json:"price"`
+	PricePerNight float64 `json:"pricePerNight"`
+	Nights        float64 `json:"nights"`
+	RedEyeLegs    float64 `json:"redEyeLegs"`
+	WeekendLegs   float64 `json:"weekendLegs"`
+}
+
+// SelectionConfig controls how diverse the top offers of every month are.
+type SelectionConfig struct {
+	Mode           string `json:"mode"`
+	MaxPerOutbound int    `json:"maxPerOutbound"`
+}
+
+// LimitsConfig caps the offers shown in the report. PerMonth is keyed by report bucket,
+// e.g. 2024-05 or 2024-W07, and overrides OffersPerMonth. PerRoute is keyed by outbound route, e.g. WMI-ALC.
+type LimitsConfig struct {
+	OffersPerMonth int            `json:"offersPerMonth"`
+	PerMonth       map[string]int `json:"perMonth"`
+	PerRoute       map[string]int `json:"perRoute"`
+	ShowMore       bool           `json:"showMore"`
+}
+
+// ChartsConfig sends price charts of every direction after the digest to telegram targets.
+// With the history store the price trend of the best offer is charted too.
+type ChartsConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// AttachmentsConfig attaches every paired trip of the target as a json, csv or ndjson document
+// after the digest to telegram targets. Empty Format attaches nothing.
+type AttachmentsConfig struct {
+	Format string `json:"format"`
+}
+
+// DaemonConfig schedules jobs of the daemon run mode.
+type DaemonConfig struct {
+	Jobs []DaemonJob `json:"jobs"`
+}
+
+// DaemonJob runs a run mode: digest, price-drops, thresholds or history, on a cron schedule
+// in local time, e.g. 0 8 * * 1-5. Name tells apart jobs running the same mode.
+type DaemonJob struct {
+	Name     string `json:"name"`
+	Mode     string `json:"mode"`
+	Schedule string `json:"schedule"`
+}
+
+// ServerConfig controls the server run mode. Ryanair and NBP responses are cached for
+// CacheTTLSeconds, zero disables caching.
+type ServerConfig struct {
+	ListenAddr      string `json:"listenAddr"`
+	CacheTTLSeconds int    `json:"cacheTTLSeconds"`
+}
+
+// MetricsConfig serves Prometheus metrics on ListenAddr in the daemon mode, e.g. :9090.
+// The server mode serves them on its own /metrics.
+type MetricsConfig struct {
+	ListenAddr string `json:"listenAddr"`
+}
+
+// HistoryConfig enables the fare history store when Path is set.
+type HistoryConfig struct {
+	Path          string `json:"path"`
+	RetentionDays int    `json:"retentionDays"`
+}
+
+// AlertsConfig controls the price-drops run mode.
+type AlertsConfig struct {
+	MinDropPercent float64 `json:"minDropPercent"`
+	MaxPriceDrops  int     `json:"maxPriceDrops"`
+}
+
+// ThresholdsConfig controls the thresholds run mode. The full digest is sent
+// at most every DigestIntervalHours, zero disables it.
+type ThresholdsConfig struct {
+	Rules               []AlertRule `json:"rules"`
+	MaxMatchesPerRule   int         `json:"maxMatchesPerRule"`
+	DigestIntervalHours int         `json:"digestIntervalHours"`
+}
+
+// DedupeConfig suppresses notifications about trips already sent to a chat,
+// until the cooldown expires or the trip total changes by MinPriceChange.
+type DedupeConfig struct {
+	Enabled        bool    `json:"enabled"`
+	MinPriceChange float64 `json:"minPriceChange"`
+	CooldownHours  int     `json:"cooldownHours"`
+}
+
+// TargetConfig describes where notifications go. Type is one of telegram, slack, discord,
+// email or webhook, and only fields of that type are used. Preferences personalise reports of the target.
+type TargetConfig struct {
+	Preferences
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	ChatID   string   `json:"chatId"`
+	BotToken string   `json:"botToken"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret"`
+	SMTPAddr string   `json:"smtpAddr"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// ParseMode overrides telegram.parseMode for a telegram target
+	ParseMode *string `json:"parseMode"`
+}
+
+// TelegramConfig sets how telegram messages are formatted: HTML, MarkdownV2 or empty for plain text.
+type TelegramConfig struct {
+	ParseMode string `json:"parseMode"`
+}
+
+// BotConfig controls the bot and webhook run modes. Only chats on AllowedChats may send commands.
+type BotConfig struct {
+	Token              string        `json:"token"`
+	AllowedChats       []string      `json:"allowedChats"`
+	PollTimeoutSeconds int           `json:"pollTimeoutSeconds"`
+	Webhook            WebhookConfig `json:"webhook"`
+}
+
+// WebhookConfig controls the webhook run mode. URL is the public address telegram posts updates to,
+// e.g. behind a reverse proxy, forwarded to Path on ListenAddr. Requests without SecretToken are rejected.
+type WebhookConfig struct {
+	ListenAddr  string `json:"listenAddr"`
+	Path        string `json:"path"`
+	URL         string `json:"url"`
+	SecretToken string `json:"secretToken"`
+}
+
+// TemplatesConfig sets the default report language, pl or en. Path is a text/template file
+// parsed over the built-in templates, it may redefine digest, priceDrops, alerts, trips or their parts.
+type TemplatesConfig struct {
+	Language string `json:"language"`
+	Path     string `json:"path"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		LookForwardInMonths: 5,
+		Bucketing:           departureMonthBucketing,
+		Ranking: RankingConfig{
+			Strategy:      totalPriceStrategyName,
+			RedEyePenalty: 50,
+			WeekendBonus:  40,
+			Weights: RankingWeights{
+				Price: 1,
+			},
+		},
+		Selection: SelectionConfig{
+			Mode:           topSelectionMode,
+			MaxPerOutbound: 2,
+		},
+		Limits: LimitsConfig{
+			OffersPerMonth: 5,
+			ShowMore:       true,
+		},
+		History: HistoryConfig{
+			RetentionDays: 180,
+		},
+		Alerts: AlertsConfig{
+			MinDropPercent: 5,
+			MaxPriceDrops:  10,
+		},
+		Thresholds: ThresholdsConfig{
+			MaxMatchesPerRule: 5,
+		},
+		Telegram: TelegramConfig{
+			ParseMode: htmlParseMode,
+		},
+		Templates: TemplatesConfig{
+			Language: polishLanguage,
+		},
+		Bot: BotConfig{
+			PollTimeoutSeconds: 25,
+			Webhook: WebhookConfig{
+				ListenAddr: ":8080",
+				Path:       "/telegram/webhook",
+			},
+		},
+		Dedupe: DedupeConfig{
+			MinPriceChange: 10,
+			CooldownHours:  24,
+		},
+		Server: ServerConfig{
+			ListenAddr:      ":8081",
+			CacheTTLSeconds: 600,
+		},
+	}
+}
+
+func loadConfig(path string) (Config, error) {
+	config := defaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("could not read config file: %v", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("error unmarshalling config file %s: %v", path, err)
+	}
+	if config.LookForwardInMonths <= 0 {
+		return config, fmt.Errorf("lookForwardInMonths must be greater than 0, got: %d", config.LookForwardInMonths)
+	}
+	if config.Limits.OffersPerMonth <= 0 {
+		return config, fmt.Errorf("limits.offersPerMonth must be greater than 0, got: %d", config.Limits.OffersPerMonth)
+	}
+	if config.Alerts.MaxPriceDrops <= 0 {
+		return config, fmt.Errorf("alerts.maxPriceDrops must be greater than 0, got: %d", config.Alerts.MaxPriceDrops)
+	}
+	if config.Thresholds.MaxMatchesPerRule <= 0 {
+		return config, fmt.Errorf("thresholds.maxMatchesPerRule must be greater than 0, got: %d", config.Thresholds.MaxMatchesPerRule)
+	}
+	return config, nil
+}
+
+func (limits LimitsConfig) forBucket(bucket Bucket) offerLimits {
+	total := limits.OffersPerMonth
+	if perMonth, ok := limits.PerMonth[bucket.String()]; ok {
+		total = perMonth
+	}
+	return offerLimits{total: total, perRoute: limits.PerRoute}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_cronSchedule_next(t *testing.T) {
+	// 2024-09-02 is a monday
+	after := time.Date(2024, 9, 2, 8, 30, 15, 0, time.UTC)
+	tests := []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"* * * * *", time.Date(2024, 9, 2, 8, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 9, 2, 8, 45, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2024, 9, 3, 8, 0, 0, 0, time.UTC)},
+		{"0 7-9 * * *", time.Date(2024, 9, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 8,20 * * *", time.Date(2024, 9, 2, 20, 0, 0, 0, time.UTC)},
+		{"0 8 * * 6,7", time.Date(2024, 9, 7, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 0", time.Date(2024, 9, 8, 8, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 15 * 3", time.Date(2024, 9, 4, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 9, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.schedule, func(t *testing.T) {
+			schedule, err := parseCronSchedule(test.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.next(after); !got.Equal(test.want) {
+				t.Errorf("got: %v != want: %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_parseCronSchedule_invalid(t *testing.T) {
+	for _, schedule := range []string{"", "0 8 * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly"} {
+		if _, err := parseCronSchedule(schedule); err == nil {
+			t.Errorf("expected error for %q", schedule)
+		}
+	}
+}
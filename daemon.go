@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// scheduledJob is a daemon job with the time of its next run.
+type scheduledJob struct {
+	key      string
+	mode     string
+	schedule cronSchedule
+	next     time.Time
+}
+
+// scheduler runs due jobs one at a time, so runs never overlap. now and wait are replaced in tests,
+// wait returns false when the context is done before the duration passed.
+type scheduler struct {
+	jobs []scheduledJob
+	run  func(job scheduledJob, now time.Time) error
+	now  func() time.Time
+	wait func(ctx context.Context, d time.Duration) bool
+}
+
+func newScheduledJobs(jobs []DaemonJob) ([]scheduledJob, error) {
+	if len(jobs) == 0 {
+		return nil, errors.New("daemon mode needs daemon.jobs in config file")
+	}
+	var scheduled []scheduledJob
+	keys := make(map[string]bool)
+	for i, job := range jobs {
+		switch job.Mode {
+		case digestRunMode, priceDropsRunMode, thresholdsRunMode, historyRunMode:
+		default:
+			return nil, fmt.Errorf("daemon job %d: unknown mode: %s. available modes: %s, %s, %s, %s",
+				i+1, job.Mode, digestRunMode, priceDropsRunMode, thresholdsRunMode, historyRunMode)
+		}
+		schedule, err := parseCronSchedule(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("daemon job %d: %v", i+1, err)
+		}
+		key := job.Name
+		if key == "" {
+			key = job.Mode
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("daemon job %d: name %s is not unique, name jobs running the same mode", i+1, key)
+		}
+		keys[key] = true
+		scheduled = append(scheduled, scheduledJob{key: key, mode: job.Mode, schedule: schedule})
+	}
+	return scheduled, nil
+}
+
+// firstRun is the next scheduled run after the last one. A run missed while the daemon
+// was stopped happens right away, once.
+func (job scheduledJob) firstRun(lastRun, now time.Time) time.Time {
+	if lastRun.IsZero() {
+		return job.schedule.next(now)
+	}
+	next := job.schedule.next(lastRun)
+	if !next.IsZero() && next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// runDaemon runs jobs on their schedules until interrupted. A job being run when the signal
+// comes is finished first. Last runs are kept in the state file.
+func runDaemon(strategy RankingStrategy) error {
+	jobs, err := newScheduledJobs(config.Daemon.Jobs)
+	if err != nil {
+		return err
+	}
+	state, err := loadState(config.StatePath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range jobs {
+		jobs[i].next = jobs[i].firstRun(state.LastRuns[jobs[i].key], now)
+		slog.Info("Job scheduled", "job", jobs[i].key, "at", jobs[i].next)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if config.Metrics.ListenAddr != "" {
+		go serveMetrics(ctx, config.Metrics.ListenAddr)
+	}
+	s := &scheduler{
+		jobs: jobs,
+		run: func(job scheduledJob, now time.Time) error {
+			if err := run(now, job.mode, strategy); err != nil {
+				return err
+			}
+			lastSuccess.set(float64(now.Unix()), job.key)
+			return recordLastRun(job.key, now)
+		},
+		now:  time.Now,
+		wait: waitContext,
+	}
+	s.loop(ctx)
+	slog.Info("Daemon stopped")
+	return nil
+}
+
+// loop runs the earliest due job until the context is done. The next run of a job is scheduled
+// after it finished, so runs it would overlap with are skipped.
+func (s *scheduler) loop(ctx context.Context) {
+	for {
+		var due *scheduledJob
+		for i := range s.jobs {
+			if !s.jobs[i].next.IsZero() && (due == nil || s.jobs[i].next.Before(due.next)) {
+				due = &s.jobs[i]
+			}
+		}
+		if due == nil {
+			slog.Warn("No job is scheduled anymore")
+			<-ctx.Done()
+			return
+		}
+		if !s.wait(ctx, due.next.Sub(s.now())) {
+			return
+		}
+		started := s.now()
+		slog.Info("Running job", "job", due.key)
+		if err := s.run(*due, started); err != nil {
+			slog.Error("Job failed", "job", due.key, "duration", s.now().Sub(started), "error", err)
+		} else {
+			slog.Info("Job finished", "job", due.key, "duration", s.now().Sub(started))
+		}
+		due.next = due.schedule.next(s.now())
+		slog.Info("Job scheduled", "job", due.key, "at", due.next)
+	}
+}
+
+func waitContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// recordLastRun keeps the time of the last successful run of the job in the state file.
+func recordLastRun(key string, now time.Time) error {
+	// a dry run leaves the state as it was
+	if config.StatePath == "" || dryRun {
+		return nil
+	}
+	return updateState(config.StatePath, func(state *State) error {
+		if state.LastRuns == nil {
+			state.LastRuns = make(map[string]time.Time)
+		}
+		state.LastRuns[key] = now
+		return nil
+	})
+}
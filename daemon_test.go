@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_newScheduledJobs(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobs    []DaemonJob
+		wantErr bool
+	}{
+		{"digest and alerts", []DaemonJob{{"", digestRunMode, "0 8 * * *"}, {"", thresholdsRunMode, "*/30 * * * *"}}, false},
+		{"named jobs of the same mode", []DaemonJob{{"morning", digestRunMode, "0 8 * * *"}, {"evening", digestRunMode, "0 20 * * *"}}, false},
+		{"no jobs", nil, true},
+		{"unknown mode", []DaemonJob{{"", botRunMode, "0 8 * * *"}}, true},
+		{"invalid schedule", []DaemonJob{{"", historyRunMode, "every hour"}}, true},
+		{"same mode without names", []DaemonJob{{"", digestRunMode, "0 8 * * *"}, {"", digestRunMode, "0 20 * * *"}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newScheduledJobs(test.jobs)
+			if (err != nil) != test.wantErr {
+				t.Errorf("error, got: %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_scheduledJob_firstRun(t *testing.T) {
+	schedule, _ := parseCronSchedule("0 8 * * *")
+	job := scheduledJob{key: digestRunMode, mode: digestRunMode, schedule: schedule}
+	now := time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		lastRun time.Time
+		want    time.Time
+	}{
+		{"never run", time.Time{}, time.Date(2024, 9, 3, 8, 0, 0, 0, time.UTC)},
+		{"run today", time.Date(2024, 9, 2, 8, 0, 0, 0, time.UTC), time.Date(2024, 9, 3, 8, 0, 0, 0, time.UTC)},
+		{"missed while stopped", time.Date(2024, 8, 30, 8, 0, 0, 0, time.UTC), now},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := job.firstRun(test.lastRun, now); !got.Equal(test.want) {
+				t.Errorf("got: %v != want: %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_scheduler_loop(t *testing.T) {
+	hourly, _ := parseCronSchedule("0 * * * *")
+	halfHourly, _ := parseCronSchedule("*/30 * * * *")
+	start := time.Date(2024, 9, 2, 8, 0, 0, 0, time.UTC)
+	clock := start
+	type run struct {
+		key string
+		at  time.Time
+	}
+	var runs []run
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &scheduler{
+		jobs: []scheduledJob{
+			{key: digestRunMode, schedule: hourly, next: start.Add(time.Hour)},
+			{key: thresholdsRunMode, schedule: halfHourly, next: start.Add(30 * time.Minute)},
+		},
+		run: func(job scheduledJob, now time.Time) error {
+			runs = append(runs, run{job.key, now})
+			if job.key == digestRunMode {
+				// the digest runs past the next two half-hourly alerts
+				clock = clock.Add(65 * time.Minute)
+			}
+			if len(runs) == 4 {
+				cancel()
+			}
+			return nil
+		},
+		now: func() time.Time { return clock },
+		wait: func(ctx context.Context, d time.Duration) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			clock = clock.Add(max(d, 0))
+			return true
+		},
+	}
+	s.loop(ctx)
+
+	want := []run{
+		{thresholdsRunMode, start.Add(30 * time.Minute)},
+		{digestRunMode, start.Add(60 * time.Minute)},
+		// alerts due at 9:00 run right after the digest, those due at 9:30 and 10:00 are skipped
+		{thresholdsRunMode, start.Add(125 * time.Minute)},
+		{thresholdsRunMode, start.Add(150 * time.Minute)},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs, got: %v != want: %v", runs, want)
+	}
+	for i := range want {
+		if runs[i].key != want[i].key || !runs[i].at.Equal(want[i].at) {
+			t.Errorf("run %d, got: %v != want: %v", i, runs[i], want[i])
+		}
+	}
+}
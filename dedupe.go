@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// NotifiedTrip is the last notification about a trip sent to a chat.
+type NotifiedTrip struct {
+	Total      float64   `json:"total"`
+	NotifiedAt time.Time `json:"notifiedAt"`
+}
+
+func tripKey(trip FlightToCompare) string {
+	return trip.AbroadFlight.FlightKey + "|" + trip.ReturnFlight.FlightKey
+}
+
+// shouldNotify is true when the trip was never sent to the chat, its cooldown expired
+// or its total changed by at least MinPriceChange since the last notification.
+func (state *State) shouldNotify(chat string, trip FlightToCompare, now time.Time, dedupe DedupeConfig) bool {
+	if !dedupe.Enabled {
+		return true
+	}
+	notified, ok := state.Notified[chat][tripKey(trip)]
+	if !ok {
+		return true
+	}
+	if now.Sub(notified.NotifiedAt) >= time.Duration(dedupe.CooldownHours)*time.Hour {
+		return true
+	}
+	return math.Abs(trip.totalPrice()-notified.Total) >= dedupe.MinPriceChange
+}
+
+func (state *State) markNotified(chat string, trips []FlightToCompare, now time.Time) {
+	if state.Notified == nil {
+		state.Notified = make(map[string]map[string]NotifiedTrip)
+	}
+	if state.Notified[chat] == nil {
+		state.Notified[chat] = make(map[string]NotifiedTrip)
+	}
+	for _, trip := range trips {
+		state.Notified[chat][tripKey(trip)] = NotifiedTrip{trip.totalPrice(), now}
+	}
+}
+
+// forgetExpired drops notifications older than the cooldown, they would not suppress anything anymore.
+func (state *State) forgetExpired(now time.Time, dedupe DedupeConfig) {
+	for chat, trips := range state.Notified {
+		for key, notified := range trips {
+			if now.Sub(notified.NotifiedAt) >= time.Duration(dedupe.CooldownHours)*time.Hour {
+				delete(trips, key)
+			}
+		}
+		if len(trips) == 0 {
+			delete(state.Notified, chat)
+		}
+	}
+}
+
+func (state *State) filterNotified(chat string, trips []FlightToCompare, now time.Time, dedupe DedupeConfig) []FlightToCompare {
+	var fresh []FlightToCompare
+	for _, trip := range trips {
+		if state.shouldNotify(chat, trip, now, dedupe) {
+			fresh = append(fresh, trip)
+		}
+	}
+	return fresh
+}
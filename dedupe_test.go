@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_State_shouldNotify(t *testing.T) {
+	dedupe := DedupeConfig{Enabled: true, MinPriceChange: 10, CooldownHours: 24}
+	notifiedAt := time.Date(2024, time.October, 1, 8, 0, 0, 0, time.UTC)
+	trip := getMockTrip("2024-10-20T12:00:00", 100, "2024-10-25T12:00:00", 100)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	var state State
+	state.markNotified("123", []FlightToCompare{trip}, notifiedAt)
+	if err := saveState(path, state); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cheaper := getMockTrip("2024-10-20T12:00:00", 85, "2024-10-25T12:00:00", 100)
+	slightlyCheaper := getMockTrip("2024-10-20T12:00:00", 95, "2024-10-25T12:00:00", 100)
+	tests := []struct {
+		name string
+		chat string
+		trip FlightToCompare
+		now  time.Time
+		want bool
+	}{
+		{
+			name: "same trip and price within cooldown",
+			chat: "123",
+			trip: trip,
+			now:  notifiedAt.Add(time.Hour),
+			want: false,
+		},
+		{
+			name: "same trip sent to another chat",
+			chat: "456",
+			trip: trip,
+			now:  notifiedAt.Add(time.Hour),
+			want: true,
+		},
+		{
+			name: "cooldown expired",
+			chat: "123",
+			trip: trip,
+			now:  notifiedAt.Add(24 * time.Hour),
+			want: true,
+		},
+		{
+			name: "price changed enough",
+			chat: "123",
+			trip: cheaper,
+			now:  notifiedAt.Add(time.Hour),
+			want: true,
+		},
+		{
+			name: "price changed too little",
+			chat: "123",
+			trip: slightlyCheaper,
+			now:  notifiedAt.Add(time.Hour),
+			want: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := state.shouldNotify(test.chat, test.trip, test.now, dedupe); got != test.want {
+				t.Errorf("should notify, got: %v != want: %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	jsonFormat   = "json"
+	csvFormat    = "csv"
+	ndjsonFormat = "ndjson"
+
+	stdoutOutput = "-"
+)
+
+// tripRecord is a paired trip in machine-readable output. Prices are in PLN, original prices
+// are in the currency ryanair quoted them and EuroRate is the rate used to convert them.
+type tripRecord struct {
+	Route    string    `json:"route"`
+	Nights   int       `json:"nights"`
+	Total    float64   `json:"total"`
+	Currency string    `json:"currency"`
+	EuroRate float64   `json:"euroRate"`
+	Outbound legRecord `json:"outbound"`
+	Return   legRecord `json:"return"`
+}
+
+type legRecord struct {
+	FlightNumber     string  `json:"flightNumber"`
+	DepartureAirport string  `json:"departureAirport"`
+	ArrivalAirport   string  `json:"arrivalAirport"`
+	DepartureTime    string  `json:"departureTime"`
+	ArrivalTime      string  `json:"arrivalTime"`
+	Price            float64 `json:"price"`
+	Currency         string  `json:"currency"`
+	OriginalPrice    float64 `json:"originalPrice"`
+	OriginalCurrency string  `json:"originalCurrency"`
+}
+
+var tripCSVHeader = []string{
+	"route", "nights", "total", "currency", "euro_rate",
+	"outbound_flight_number", "outbound_departure_airport", "outbound_arrival_airport",
+	"outbound_departure_time", "outbound_arrival_time", "outbound_price", "outbound_currency",
+	"outbound_original_price", "outbound_original_currency",
+	"return_flight_number", "return_departure_airport", "return_arrival_airport",
+	"return_departure_time", "return_arrival_time", "return_price", "return_currency",
+	"return_original_price", "return_original_currency",
+}
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case jsonFormat, csvFormat, ndjsonFormat:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format: %s. available formats: %s, %s, %s", format, jsonFormat, csvFormat, ndjsonFormat)
+	}
+}
+
+func newTripRecord(trip FlightToCompare, euroRate float64) tripRecord {
+	nights, _ := trip.nights()
+	return tripRecord{
+		Route:    trip.route(),
+		Nights:   nights,
+		Total:    trip.totalPrice(),
+		Currency: trip.AbroadFlight.Price.CurrencyCode,
+		EuroRate: euroRate,
+		Outbound: newLegRecord(trip.AbroadFlight),
+		Return:   newLegRecord(trip.ReturnFlight),
+	}
+}
+
+func newLegRecord(flight Outbound) legRecord {
+	original := flight.Price
+	if flight.OriginalPrice != nil {
+		original = *flight.OriginalPrice
+	}
+	return legRecord{
+		FlightNumber:     flight.FlightNumber,
+		DepartureAirport: flight.DepartureAirport.IATACode,
+		ArrivalAirport:   flight.ArrivalAirport.IATACode,
+		DepartureTime:    flight.DepartureDate,
+		ArrivalTime:      flight.ArrivalDate,
+		Price:            flight.Price.Value,
+		Currency:         flight.Price.CurrencyCode,
+		OriginalPrice:    original.Value,
+		OriginalCurrency: original.CurrencyCode,
+	}
+}
+
+func (leg legRecord) csv() []string {
+	return []string{
+		leg.FlightNumber, leg.DepartureAirport, leg.ArrivalAirport,
+		leg.DepartureTime, leg.ArrivalTime, formatCSVFloat(leg.Price), leg.Currency,
+		formatCSVFloat(leg.OriginalPrice), leg.OriginalCurrency,
+	}
+}
+
+func formatCSVFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// runExports writes trips of the default routes: all of them in -format and to the -html report
+// and the top trips of the digest to the -ics calendar.
+func runExports(now time.Time, fares legFares, euroRate float64, strategy RankingStrategy) error {
+	flightsToCompare, err := Preferences{}.trips(fares)
+	if err != nil {
+		return err
+	}
+	if calendarPath != "" {
+		var offers []FlightToCompare
+		for _, section := range buildReport(now, flightsToCompare, strategy) {
+			offers = append(offers, section.Offers...)
+		}
+		if err := exportCalendar(calendarPath, offers, now); err != nil {
+			return err
+		}
+	}
+	trips := rankedTrips(flightsToCompare, strategy)
+	if htmlReportPath != "" {
+		if err := exportHTMLReport(htmlReportPath, now, Preferences{}, fares, trips); err != nil {
+			return err
+		}
+	}
+	if outputFormat != "" {
+		return exportTrips(outputPath, outputFormat, trips, euroRate)
+	}
+	return nil
+}
+
+// rankedTrips lists trips bucket after bucket, ranked with the strategy within every bucket.
+func rankedTrips(flightsToCompare map[Bucket][]FlightToCompare, strategy RankingStrategy) []FlightToCompare {
+	buckets := make([]Bucket, 0, len(flightsToCompare))
+	for bucket := range flightsToCompare {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].before(buckets[j]) })
+	var trips []FlightToCompare
+	for _, bucket := range buckets {
+		rankTrips(flightsToCompare[bucket], strategy)
+		trips = append(trips, flightsToCompare[bucket]...)
+	}
+	return trips
+}
+
+// exportTrips writes trips in the format to path, stdoutOutput writes to standard output.
+func exportTrips(path, format string, trips []FlightToCompare, euroRate float64) error {
+	if path == stdoutOutput {
+		return writeTrips(os.Stdout, format, trips, euroRate)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %v", err)
+	}
+	if err := writeTrips(file, format, trips, euroRate); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeTrips(w io.Writer, format string, trips []FlightToCompare, euroRate float64) error {
+	records := make([]tripRecord, 0, len(trips))
+	for _, trip := range trips {
+		records = append(records, newTripRecord(trip, euroRate))
+	}
+	switch format {
+	case jsonFormat:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case ndjsonFormat:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case csvFormat:
+		writer := csv.NewWriter(w)
+		writer.Write(tripCSVHeader)
+		for _, record := range records {
+			row := []string{
+				record.Route, strconv.Itoa(record.Nights), formatCSVFloat(record.Total), record.Currency, formatCSVFloat(record.EuroRate),
+			}
+			row = append(row, record.Outbound.csv()...)
+			writer.Write(append(row, record.Return.csv()...))
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return validateOutputFormat(format)
+	}
+}
+
+// sendTripsDocument attaches trips in the attachments format, so the digest stays short
+// while every candidate trip is one tap away.
+func sendTripsDocument(notifier documentNotifier, now time.Time, trips []FlightToCompare, euroRate float64) error {
+	var document bytes.Buffer
+	if err := writeTrips(&document, config.Attachments.Format, trips, euroRate); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("trips-%s.%s", now.Format("2006-01-02"), config.Attachments.Format)
+	return notifier.NotifyDocument(name, fmt.Sprintf("All trips: %d", len(trips)), document.Bytes())
+}
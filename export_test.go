@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_writeTrips(t *testing.T) {
+	returnFares := []Fare{mockFare("ALC", "WMI", "2024-10-10T12:00:00", 20, "EUR")}
+	convertFaresToPLN(returnFares, 4.3)
+	outboundFares := []Fare{mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN")}
+	convertFaresToPLN(outboundFares, 4.3)
+	trips := []FlightToCompare{
+		{outboundFares[0].Outbound, returnFares[0].Outbound},
+		{outboundFares[0].Outbound, returnFares[0].Outbound},
+	}
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, output string)
+	}{
+		{
+			format: jsonFormat,
+			check: func(t *testing.T, output string) {
+				var records []tripRecord
+				if err := json.Unmarshal([]byte(output), &records); err != nil {
+					t.Fatal(err)
+				}
+				if len(records) != 2 {
+					t.Fatalf("records, got: %d != want: %d", len(records), 2)
+				}
+				got := records[0]
+				if got.Route != "WMI-ALC" || got.Nights != 6 || got.Total != 166 || got.EuroRate != 4.3 {
+					t.Errorf("unexpected record: %+v", got)
+				}
+				if got.Return.Price != 86 || got.Return.Currency != "PLN" ||
+					got.Return.OriginalPrice != 20 || got.Return.OriginalCurrency != "EUR" {
+					t.Errorf("unexpected return leg: %+v", got.Return)
+				}
+			},
+		},
+		{
+			format: ndjsonFormat,
+			check: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSpace(output), "\n")
+				if len(lines) != 2 {
+					t.Fatalf("lines, got: %d != want: %d", len(lines), 2)
+				}
+				var record tripRecord
+				if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+					t.Fatal(err)
+				}
+				if record.Outbound.FlightNumber != "FRWMI" || record.Outbound.DepartureTime != "2024-10-04T12:00:00" {
+					t.Errorf("unexpected outbound leg: %+v", record.Outbound)
+				}
+			},
+		},
+		{
+			format: csvFormat,
+			check: func(t *testing.T, output string) {
+				rows, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rows) != 3 || len(rows[1]) != len(tripCSVHeader) {
+					t.Fatalf("unexpected rows: %v", rows)
+				}
+				want := []string{"WMI-ALC", "6", "166", "PLN", "4.3", "FRWMI"}
+				for i, value := range want {
+					if rows[1][i] != value {
+						t.Errorf("%s, got: %s != want: %s", tripCSVHeader[i], rows[1][i], value)
+					}
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := writeTrips(&output, test.format, trips, 4.3); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, output.String())
+		})
+	}
+}
+
+func Test_sendTripsDocument(t *testing.T) {
+	var name, caption, chat string
+	var content []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottoken/sendDocument" {
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		content, _ = io.ReadAll(file)
+		name, caption, chat = header.Filename, r.FormValue("caption"), r.FormValue("chat_id")
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer server.Close()
+	telegramAPIURL = server.URL
+	defer func() { telegramAPIURL = "https://api.telegram.org" }()
+	defer func(previous Config) { config = previous }(config)
+	config.Attachments.Format = csvFormat
+
+	outbound := mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN").Outbound
+	inbound := mockFare("ALC", "WMI", "2024-10-10T12:00:00", 86, "PLN").Outbound
+	now := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	err := sendTripsDocument(telegramNotifier{"token", "123", plainParseMode}, now, []FlightToCompare{{outbound, inbound}}, 4.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "trips-2024-09-01.csv" || caption != "All trips: 1" || chat != "123" {
+		t.Errorf("unexpected document %s with caption %q to %s", name, caption, chat)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][0] != "WMI-ALC" {
+		t.Errorf("unexpected document rows: %v", rows)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+const (
+	plainParseMode      = ""
+	htmlParseMode       = "HTML"
+	markdownV2ParseMode = "MarkdownV2"
+
+	ryanairBookingURL = "https://www.ryanair.com/pl/pl/trip/flights/select"
+)
+
+// messageFormatter renders message parts for a telegram parse mode. Every method takes raw text
+// and escapes it as the parse mode requires.
+type messageFormatter interface {
+	parseMode() string
+	escape(text string) string
+	bold(text string) string
+	code(text string) string
+	link(text, url string) string
+	arrow() string
+}
+
+func newMessageFormatter(parseMode string) (messageFormatter, error) {
+	switch parseMode {
+	case plainParseMode:
+		return plainFormatter{}, nil
+	case htmlParseMode:
+		return htmlFormatter{}, nil
+	case markdownV2ParseMode:
+		return markdownV2Formatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown telegram parse mode: %s. available modes: %s, %s or empty for plain text",
+			parseMode, htmlParseMode, markdownV2ParseMode)
+	}
+}
+
+type plainFormatter struct{}
+
+func (plainFormatter) parseMode() string            { return plainParseMode }
+func (plainFormatter) escape(text string) string    { return text }
+func (plainFormatter) bold(text string) string      { return text }
+func (plainFormatter) code(text string) string      { return text }
+func (plainFormatter) link(text, url string) string { return text + ": " + url }
+func (plainFormatter) arrow() string                { return "--->" }
+
+type htmlFormatter struct{}
+
+func (htmlFormatter) parseMode() string         { return htmlParseMode }
+func (htmlFormatter) escape(text string) string { return html.EscapeString(text) }
+func (f htmlFormatter) bold(text string) string { return "<b>" + f.escape(text) + "</b>" }
+func (f htmlFormatter) code(text string) string { return "<code>" + f.escape(text) + "</code>" }
+func (f htmlFormatter) link(text, url string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, f.escape(url), f.escape(text))
+}
+func (htmlFormatter) arrow() string { return "→" }
+
+type markdownV2Formatter struct{}
+
+var (
+	markdownV2Escaper     = strings.NewReplacer(markdownV2Replacements("_*[]()~`>#+-=|{}.!\\")...)
+	markdownV2CodeEscaper = strings.NewReplacer(markdownV2Replacements("`\\")...)
+	markdownV2URLEscaper  = strings.NewReplacer(markdownV2Replacements(")\\")...)
+)
+
+func markdownV2Replacements(characters string) []string {
+	var replacements []string
+	for _, character := range characters {
+		replacements = append(replacements, string(character), "\\"+string(character))
+	}
+	return replacements
+}
+
+func (markdownV2Formatter) parseMode() string         { return markdownV2ParseMode }
+func (markdownV2Formatter) escape(text string) string { return markdownV2Escaper.Replace(text) }
+func (f markdownV2Formatter) bold(text string) string { return "*" + f.escape(text) + "*" }
+func (markdownV2Formatter) code(text string) string {
+	return "`" + markdownV2CodeEscaper.Replace(text) + "`"
+}
+func (f markdownV2Formatter) link(text, url string) string {
+	return "[" + f.escape(text) + "](" + markdownV2URLEscaper.Replace(url) + ")"
+}
+func (markdownV2Formatter) arrow() string { return "→" }
+
+// bookingLinks open ryanair flight selection prefilled with the trip airports and dates.
+// Trips returning to another airport than they left from are booked as two one-way flights.
+func bookingLinks(trip FlightToCompare) []string {
+	abroad, returnFlight := trip.AbroadFlight, trip.ReturnFlight
+	if abroad.DepartureAirport.IATACode == returnFlight.ArrivalAirport.IATACode &&
+		abroad.ArrivalAirport.IATACode == returnFlight.DepartureAirport.IATACode {
+		return []string{bookingURL(abroad, &returnFlight)}
+	}
+	return []string{bookingURL(abroad, nil), bookingURL(returnFlight, nil)}
+}
+
+func bookingURL(flight Outbound, returnFlight *Outbound) string {
+	dateOut, dateIn := flightDay(flight), ""
+	if returnFlight != nil {
+		dateIn = flightDay(*returnFlight)
+	}
+	query := url.Values{}
+	query.Set("adults", "1")
+	query.Set("teens", "0")
+	query.Set("children", "0")
+	query.Set("infants", "0")
+	query.Set("dateOut", dateOut)
+	query.Set("dateIn", dateIn)
+	query.Set("isConnectedFlight", "false")
+	query.Set("isReturn", fmt.Sprint(returnFlight != nil))
+	query.Set("discount", "0")
+	query.Set("promoCode", "")
+	query.Set("originIata", flight.DepartureAirport.IATACode)
+	query.Set("destinationIata", flight.ArrivalAirport.IATACode)
+	query.Set("tpAdults", "1")
+	query.Set("tpTeens", "0")
+	query.Set("tpChildren", "0")
+	query.Set("tpInfants", "0")
+	query.Set("tpStartDate", dateOut)
+	query.Set("tpEndDate", dateIn)
+	query.Set("tpDiscount", "0")
+	query.Set("tpPromoCode", "")
+	query.Set("tpOriginIata", flight.DepartureAirport.IATACode)
+	query.Set("tpDestinationIata", flight.ArrivalAirport.IATACode)
+	return ryanairBookingURL + "?" + query.Encode()
+}
+
+func flightDay(flight Outbound) string {
+	day, _, _ := strings.Cut(flight.DepartureDate, "T")
+	return day
+}
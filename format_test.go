@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_messageFormatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		parseMode string
+		want      string
+	}{
+		{
+			name:      "plain text",
+			parseMode: plainParseMode,
+			want:      "Total: 1.5 <zł> 2024-10-05 Sat 2024-10-05 Sat: https://example.com/?a=1&b=(2)",
+		},
+		{
+			name:      "html escapes tags and entities",
+			parseMode: htmlParseMode,
+			want:      `Total: <b>1.5 &lt;zł&gt;</b> <code>2024-10-05 Sat</code> <a href="https://example.com/?a=1&amp;b=(2)">2024-10-05 Sat</a>`,
+		},
+		{
+			name:      "markdownV2 escapes reserved characters",
+			parseMode: markdownV2ParseMode,
+			want:      "Total: *1\\.5 <zł\\>* `2024-10-05 Sat` [2024\\-10\\-05 Sat](https://example.com/?a=1&b=(2\\))",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := newMessageFormatter(test.parseMode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := f.escape("Total: ") + f.bold("1.5 <zł>") + " " + f.code("2024-10-05 Sat") + " " +
+				f.link("2024-10-05 Sat", "https://example.com/?a=1&b=(2)")
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+	if _, err := newMessageFormatter("Markdown"); err == nil {
+		t.Error("expected error for unsupported parse mode")
+	}
+}
+
+func Test_bookingLinks(t *testing.T) {
+	roundTrip := getMockTrip("2024-10-04T12:00:00", 80, "2024-10-20T12:00:00", 70)
+	openJaw := roundTrip
+	openJaw.ReturnFlight.ArrivalAirport = Airport{IATACode: "WAW", Name: "Warszawa-Chopin"}
+
+	tests := []struct {
+		name string
+		trip FlightToCompare
+		want []map[string]string
+	}{
+		{
+			name: "return to departure airport is one return booking",
+			trip: roundTrip,
+			want: []map[string]string{
+				{"originIata": "WMI", "destinationIata": "ALC", "dateOut": "2024-10-04", "dateIn": "2024-10-20", "isReturn": "true"},
+			},
+		},
+		{
+			name: "return to another airport is two one-way bookings",
+			trip: openJaw,
+			want: []map[string]string{
+				{"originIata": "WMI", "destinationIata": "ALC", "dateOut": "2024-10-04", "dateIn": "", "isReturn": "false"},
+				{"originIata": "ALC", "destinationIata": "WAW", "dateOut": "2024-10-20", "dateIn": "", "isReturn": "false"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			links := bookingLinks(test.trip)
+			if len(links) != len(test.want) {
+				t.Fatalf("links, got: %d != want: %d", len(links), len(test.want))
+			}
+			for i, link := range links {
+				if !strings.HasPrefix(link, ryanairBookingURL+"?") {
+					t.Errorf("link does not point to ryanair booking: %s", link)
+				}
+				parsed, err := url.Parse(link)
+				if err != nil {
+					t.Fatal(err)
+				}
+				query := parsed.Query()
+				for key, value := range test.want[i] {
+					if query.Get(key) != value {
+						t.Errorf("link %d %s, got: %s != want: %s", i, key, query.Get(key), value)
+					}
+				}
+			}
+		})
+	}
+}
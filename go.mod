@@ -2,4 +2,9 @@ module github.com/wojcikp/scrap-ryan
 
 go 1.22.1
 
-require github.com/google/go-cmp v0.6.0 // indirect
+require (
+	github.com/google/go-cmp v0.6.0
+	go.etcd.io/bbolt v1.3.11
+)
+
+require golang.org/x/sys v0.4.0 // indirect
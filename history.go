@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var faresBucket = []byte("fares")
+
+// FareObservation is a fare as it was seen at FetchedAt, in the currency returned by ryanair.
+type FareObservation struct {
+	FlightKey        string    `json:"flightKey"`
+	FlightNumber     string    `json:"flightNumber"`
+	DepartureAirport string    `json:"departureAirport"`
+	ArrivalAirport   string    `json:"arrivalAirport"`
+	DepartureDate    string    `json:"departureDate"`
+	Price            float64   `json:"price"`
+	Currency         string    `json:"currency"`
+	PreviousPrice    *float64  `json:"previousPrice"`
+	FetchedAt        time.Time `json:"fetchedAt"`
+}
+
+type historyQuery struct {
+	DepartureAirport string
+	ArrivalAirport   string
+	FetchedFrom      time.Time
+	FetchedTo        time.Time
+}
+
+// historyStore keeps fare observations in a bolt file, one nested bucket per flight key
+// with observations keyed by fetch time so they are ordered chronologically.
+type historyStore struct {
+	db *bolt.DB
+}
+
+func openHistoryStore(path string) (*historyStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open history store %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(faresBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create history bucket: %v", err)
+	}
+	return &historyStore{db}, nil
+}
+
+// openHistoryStoreReadOnly opens an existing store with a shared lock, so readers do not wait
+// for each other, only for a run recording fares.
+func openHistoryStoreReadOnly(path string) (*historyStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("could not open history store %s: %v", path, err)
+	}
+	return &historyStore{db}, nil
+}
+
+func (s *historyStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *historyStore) record(fares []Fare, fetchedAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(faresBucket)
+		for _, fare := range fares {
+			observation := FareObservation{
+				FlightKey:        fare.Outbound.FlightKey,
+				FlightNumber:     fare.Outbound.FlightNumber,
+				DepartureAirport: fare.Outbound.DepartureAirport.IATACode,
+				ArrivalAirport:   fare.Outbound.ArrivalAirport.IATACode,
+				DepartureDate:    fare.Outbound.DepartureDate,
+				Price:            fare.Outbound.Price.Value,
+				Currency:         fare.Outbound.Price.CurrencyCode,
+				PreviousPrice:    fare.Outbound.PreviousPrice,
+				FetchedAt:        fetchedAt,
+			}
+			flight, err := bucket.CreateBucketIfNotExists([]byte(observation.FlightKey))
+			if err != nil {
+				return fmt.Errorf("could not create bucket for flight %s: %v", observation.FlightKey, err)
+			}
+			value, err := json.Marshal(observation)
+			if err != nil {
+				return fmt.Errorf("error marshalling fare observation: %v", err)
+			}
+			if err := flight.Put(timeKey(fetchedAt), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flightHistory returns every observation of the flight from the oldest to the newest.
+func (s *historyStore) flightHistory(flightKey string) ([]FareObservation, error) {
+	var observations []FareObservation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		flight := tx.Bucket(faresBucket).Bucket([]byte(flightKey))
+		if flight == nil {
+			return nil
+		}
+		return flight.ForEach(func(_, value []byte) error {
+			var observation FareObservation
+			if err := json.Unmarshal(value, &observation); err != nil {
+				return fmt.Errorf("error unmarshalling fare observation: %v", err)
+			}
+			observations = append(observations, observation)
+			return nil
+		})
+	})
+	return observations, err
+}
+
+// query returns observations matching every non-zero field of the query, ordered by fetch time.
+func (s *historyStore) query(q historyQuery) ([]FareObservation, error) {
+	var observations []FareObservation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(faresBucket).ForEachBucket(func(flightKey []byte) error {
+			flight := tx.Bucket(faresBucket).Bucket(flightKey)
+			cursor := flight.Cursor()
+			key, value := cursor.First()
+			if !q.FetchedFrom.IsZero() {
+				key, value = cursor.Seek(timeKey(q.FetchedFrom))
+			}
+			for ; key != nil; key, value = cursor.Next() {
+				if !q.FetchedTo.IsZero() && string(key) > string(timeKey(q.FetchedTo)) {
+					break
+				}
+				var observation FareObservation
+				if err := json.Unmarshal(value, &observation); err != nil {
+					return fmt.Errorf("error unmarshalling fare observation: %v", err)
+				}
+				// every observation of a flight has the same airports
+				if q.DepartureAirport != "" && q.DepartureAirport != observation.DepartureAirport {
+					return nil
+				}
+				if q.ArrivalAirport != "" && q.ArrivalAirport != observation.ArrivalAirport {
+					return nil
+				}
+				observations = append(observations, observation)
+			}
+			return nil
+		})
+	})
+	sort.SliceStable(observations, func(i, j int) bool {
+		return observations[i].FetchedAt.Before(observations[j].FetchedAt)
+	})
+	return observations, err
+}
+
+// lastObservationBefore returns the newest observation of the flight fetched before the given time.
+func (s *historyStore) lastObservationBefore(flightKey string, before time.Time) (*FareObservation, error) {
+	var observation *FareObservation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		flight := tx.Bucket(faresBucket).Bucket([]byte(flightKey))
+		if flight == nil {
+			return nil
+		}
+		cursor := flight.Cursor()
+		key, value := cursor.Seek(timeKey(before))
+		if key == nil {
+			key, value = cursor.Last()
+		} else {
+			key, value = cursor.Prev()
+		}
+		if key == nil {
+			return nil
+		}
+		observation = &FareObservation{}
+		if err := json.Unmarshal(value, observation); err != nil {
+			return fmt.Errorf("error unmarshalling fare observation: %v", err)
+		}
+		return nil
+	})
+	return observation, err
+}
+
+// prune removes observations fetched before the cutoff and flights left without observations.
+func (s *historyStore) prune(cutoff time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		fares := tx.Bucket(faresBucket)
+		var emptyFlights [][]byte
+		err := fares.ForEachBucket(func(flightKey []byte) error {
+			flight := fares.Bucket(flightKey)
+			cursor := flight.Cursor()
+			for key, _ := cursor.First(); key != nil && string(key) < string(timeKey(cutoff)); key, _ = cursor.First() {
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+				removed++
+			}
+			if key, _ := cursor.First(); key == nil {
+				emptyFlights = append(emptyFlights, append([]byte{}, flightKey...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, flightKey := range emptyFlights {
+			if err := fares.DeleteBucket(flightKey); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
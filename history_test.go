@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_historyStore(t *testing.T) {
+	store, err := openHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	firstFetch := time.Date(2024, time.October, 1, 8, 0, 0, 0, time.UTC)
+	secondFetch := firstFetch.Add(24 * time.Hour)
+	fares := getMockAlcToWawFares()
+	if err := store.record(fares, firstFetch); err != nil {
+		t.Fatal(err)
+	}
+	fares[0].Outbound.Price.Value = 80
+	if err := store.record(fares[:1], secondFetch); err != nil {
+		t.Fatal(err)
+	}
+
+	flightKey := fares[0].Outbound.FlightKey
+	history, err := store.flightHistory(flightKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Price != 95 || history[1].Price != 80 || history[1].Currency != "EUR" {
+		t.Errorf("unexpected flight history: %+v", history)
+	}
+
+	previous, err := store.lastObservationBefore(flightKey, secondFetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous == nil || !previous.FetchedAt.Equal(firstFetch) {
+		t.Errorf("last observation before second fetch, got: %+v", previous)
+	}
+
+	observations, err := store.query(historyQuery{FetchedFrom: secondFetch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 1 {
+		t.Errorf("observations fetched since second fetch, got: %d != want: %d", len(observations), 1)
+	}
+
+	removed, err := store.prune(secondFetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two of the mocked fares share a flight key
+	if removed != 3 {
+		t.Errorf("pruned observations, got: %d != want: %d", removed, 3)
+	}
+	observations, err = store.query(historyQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 1 {
+		t.Errorf("observations left after prune, got: %d != want: %d", len(observations), 1)
+	}
+}
+
+func Test_openHistoryStoreReadOnly_sharesTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := openHistoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	first, err := openHistoryStoreReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	start := time.Now()
+	second, err := openHistoryStoreReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("second reader waited %v for the first one", waited)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	heatmapDayLayout = "2006-01-02"
+	// heatmapLevels colour days from the cheapest to the most expensive of a direction
+	heatmapLevels = 5
+)
+
+//go:embed templates/report.html
+var htmlReportTemplate string
+
+type htmlReportData struct {
+	Generated  string
+	Weekdays   []string
+	Directions []heatmapDirection
+	Routes     []string
+	Trips      []htmlTrip
+}
+
+// heatmapDirection is a price calendar of the cheapest fare per day of all legs in one direction.
+type heatmapDirection struct {
+	Name   string
+	Months []heatmapMonth
+}
+
+type heatmapMonth struct {
+	Name  string
+	Weeks [][]heatmapDay
+}
+
+// heatmapDay is a calendar cell, zero Day pads weeks before the first and after the last day of month.
+type heatmapDay struct {
+	Day   int
+	Price string
+	Level int
+}
+
+// htmlTrip is a row of the trips table, Departure, Nights and Total are also its sort keys.
+type htmlTrip struct {
+	Route         string
+	Departure     string
+	Outbound      string
+	Return        string
+	Nights        int
+	OutboundPrice string
+	ReturnPrice   string
+	Total         float64
+	TotalLabel    string
+	Links         []bookingLink
+}
+
+// exportHTMLReport writes a self-contained HTML report of the fares of preferences and the trips.
+func exportHTMLReport(path string, now time.Time, preferences Preferences, fares legFares, trips []FlightToCompare) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create html report: %v", err)
+	}
+	if err := writeHTMLReport(file, now, preferences, fares, trips); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeHTMLReport(w io.Writer, now time.Time, preferences Preferences, fares legFares, trips []FlightToCompare) error {
+	l := locales[config.Templates.Language]
+	t, err := template.New("report").Parse(htmlReportTemplate)
+	if err != nil {
+		return fmt.Errorf("could not parse html report template: %v", err)
+	}
+	data := htmlReportData{Generated: now.Format("2006-01-02 15:04")}
+	// calendars start on monday
+	for i := range l.weekdays {
+		data.Weekdays = append(data.Weekdays, l.weekdays[(i+1)%7])
+	}
+	for _, direction := range preferences.directions(fares) {
+		data.Directions = append(data.Directions, l.heatmap(direction.name, direction.fares))
+	}
+	for _, trip := range trips {
+		nights, err := trip.nights()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(data.Routes, trip.route()) {
+			data.Routes = append(data.Routes, trip.route())
+		}
+		data.Trips = append(data.Trips, htmlTrip{
+			Route:         trip.route(),
+			Departure:     trip.AbroadFlight.DepartureDate,
+			Outbound:      l.flightDate(trip.AbroadFlight),
+			Return:        l.flightDate(trip.ReturnFlight),
+			Nights:        nights,
+			OutboundPrice: l.formatPrice(trip.AbroadFlight.Price),
+			ReturnPrice:   l.formatPrice(trip.ReturnFlight.Price),
+			Total:         trip.totalPrice(),
+			TotalLabel:    l.formatNumber(trip.totalPrice(), 2) + " zł",
+			Links:         tripBookingLinks(trip),
+		})
+	}
+	sort.Strings(data.Routes)
+	if err := t.Execute(w, data); err != nil {
+		return fmt.Errorf("could not render html report: %v", err)
+	}
+	return nil
+}
+
+// heatmap lays out the cheapest fare of every day in month grids from the first to the last fare.
+func (l locale) heatmap(name string, fares []Fare) heatmapDirection {
+	direction := heatmapDirection{Name: name}
+	cheapest := make(map[string]float64)
+	var first, last time.Time
+	for _, fare := range fares {
+		departure, err := fare.Outbound.departureTime()
+		if err != nil {
+			continue
+		}
+		day := departure.Format(heatmapDayLayout)
+		if price, ok := cheapest[day]; !ok || fare.Outbound.Price.Value < price {
+			cheapest[day] = fare.Outbound.Price.Value
+		}
+		if first.IsZero() || departure.Before(first) {
+			first = departure
+		}
+		if departure.After(last) {
+			last = departure
+		}
+	}
+	if len(cheapest) == 0 {
+		return direction
+	}
+	minPrice, maxPrice := math.Inf(1), math.Inf(-1)
+	for _, price := range cheapest {
+		minPrice, maxPrice = math.Min(minPrice, price), math.Max(maxPrice, price)
+	}
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(last); month = month.AddDate(0, 1, 0) {
+		grid := heatmapMonth{Name: fmt.Sprintf("%s %d", l.months[month.Month()-1], month.Year())}
+		// pad the first week up to the weekday of the 1st, monday first
+		week := make([]heatmapDay, (int(month.Weekday())+6)%7)
+		for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+			cell := heatmapDay{Day: day.Day()}
+			if price, ok := cheapest[day.Format(heatmapDayLayout)]; ok {
+				cell.Price = l.formatNumber(price, 0)
+				cell.Level = heatmapLevel(price, minPrice, maxPrice)
+			}
+			week = append(week, cell)
+			if len(week) == 7 {
+				grid.Weeks = append(grid.Weeks, week)
+				week = nil
+			}
+		}
+		if len(week) > 0 {
+			grid.Weeks = append(grid.Weeks, append(week, make([]heatmapDay, 7-len(week))...))
+		}
+		direction.Months = append(direction.Months, grid)
+	}
+	return direction
+}
+
+// heatmapLevel is 1 for the cheapest to heatmapLevels for the most expensive price, 0 is no fare.
+func heatmapLevel(price, minPrice, maxPrice float64) int {
+	if maxPrice == minPrice {
+		return 1
+	}
+	return 1 + int(math.Round((price-minPrice)/(maxPrice-minPrice)*(heatmapLevels-1)))
+}
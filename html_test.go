@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_locale_heatmap(t *testing.T) {
+	fares := []Fare{
+		mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN"),
+		mockFare("WAW", "ALC", "2024-10-04T18:00:00", 60, "PLN"),
+		mockFare("WMI", "ALC", "2024-10-31T06:00:00", 300, "PLN"),
+		mockFare("WMI", "ALC", "2024-11-02T06:00:00", 180, "PLN"),
+	}
+	got := locales[englishLanguage].heatmap("WMI, WAW → ALC", fares)
+	if len(got.Months) != 2 || got.Months[0].Name != "October 2024" || got.Months[1].Name != "November 2024" {
+		t.Fatalf("unexpected months: %+v", got.Months)
+	}
+	october := got.Months[0].Weeks
+	// 2024-10-01 is a tuesday
+	if len(october) != 5 || october[0][0].Day != 0 || october[0][1].Day != 1 {
+		t.Fatalf("unexpected october grid: %+v", october)
+	}
+	tests := []struct {
+		day       heatmapDay
+		wantDay   int
+		wantPrice string
+		wantLevel int
+	}{
+		{october[0][4], 4, "60", 1},
+		{october[4][3], 31, "300", heatmapLevels},
+		{got.Months[1].Weeks[0][5], 2, "180", 3},
+		{october[1][0], 7, "", 0},
+	}
+	for _, test := range tests {
+		if test.day.Day != test.wantDay || test.day.Price != test.wantPrice || test.day.Level != test.wantLevel {
+			t.Errorf("got: %+v != want: day %d price %s level %d", test.day, test.wantDay, test.wantPrice, test.wantLevel)
+		}
+	}
+}
+
+func Test_writeHTMLReport(t *testing.T) {
+	fares := legFares{
+		{"WMI", "ALC"}: {mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN")},
+		{"ALC", "WMI"}: {mockFare("ALC", "WMI", "2024-10-10T12:00:00", 86, "PLN")},
+	}
+	preferences := Preferences{Routes: []string{"WMI-ALC"}}
+	trips, err := preferences.trips(fares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strategy, _ := newRankingStrategy(config.Ranking)
+
+	var output bytes.Buffer
+	now := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	if err := writeHTMLReport(&output, now, preferences, fares, rankedTrips(trips, strategy)); err != nil {
+		t.Fatal(err)
+	}
+	report := output.String()
+	for _, want := range []string{
+		"<h2>WMI → ALC</h2>", "<h2>ALC → WMI</h2>", "<caption>Październik 2024</caption>",
+		`<td class="level-1">4<span>80</span></td>`,
+		`<tr data-route="WMI-ALC" data-departure="2024-10-04T12:00:00" data-nights="6" data-total="166">`,
+		"166,00 zł", `<a href="https://www.ryanair.com/`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	for _, external := range []string{"<link", "src=", "@import", "url("} {
+		if strings.Contains(report, external) {
+			t.Errorf("report refers to an external asset with %q", external)
+		}
+	}
+}
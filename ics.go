@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsDateTimeLayout = "20060102T150405"
+	icsLineLimit      = 75
+)
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// exportCalendar writes trips as tentative events to an iCalendar file.
+func exportCalendar(path string, trips []FlightToCompare, now time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create calendar file: %v", err)
+	}
+	if err := writeCalendar(file, trips, now); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeCalendar writes every trip as an event from outbound departure to return arrival.
+// Ryanair times are local airport times, so they are written as floating times.
+func writeCalendar(w io.Writer, trips []FlightToCompare, now time.Time) error {
+	var calendar strings.Builder
+	writeICSLine(&calendar, "BEGIN:VCALENDAR")
+	writeICSLine(&calendar, "VERSION:2.0")
+	writeICSLine(&calendar, "PRODID:-//scrap-ryan//trips//EN")
+	writeICSLine(&calendar, "CALSCALE:GREGORIAN")
+	for _, trip := range trips {
+		start, err := time.Parse(ryanDateLayout, trip.AbroadFlight.DepartureDate)
+		if err != nil {
+			return err
+		}
+		end, err := time.Parse(ryanDateLayout, trip.ReturnFlight.ArrivalDate)
+		if err != nil {
+			// fares without arrival time end at the return departure
+			if end, err = time.Parse(ryanDateLayout, trip.ReturnFlight.DepartureDate); err != nil {
+				return err
+			}
+		}
+		uid := sha256.Sum256([]byte(tripKey(trip)))
+		links := bookingLinks(trip)
+
+		writeICSLine(&calendar, "BEGIN:VEVENT")
+		writeICSLine(&calendar, "UID:"+hex.EncodeToString(uid[:16])+"@scrap-ryan")
+		writeICSLine(&calendar, "DTSTAMP:"+now.UTC().Format(icsDateTimeLayout)+"Z")
+		writeICSLine(&calendar, "DTSTART:"+start.Format(icsDateTimeLayout))
+		writeICSLine(&calendar, "DTEND:"+end.Format(icsDateTimeLayout))
+		writeICSLine(&calendar, "STATUS:TENTATIVE")
+		writeICSLine(&calendar, "TRANSP:TRANSPARENT")
+		writeICSLine(&calendar, "SUMMARY:"+icsTextEscaper.Replace(fmt.Sprintf("%s %s-%s %.2f PLN",
+			trip.route(), trip.ReturnFlight.DepartureAirport.IATACode, trip.ReturnFlight.ArrivalAirport.IATACode, trip.totalPrice())))
+		writeICSLine(&calendar, "DESCRIPTION:"+icsTextEscaper.Replace(tripDescription(trip, links)))
+		writeICSLine(&calendar, "URL:"+links[0])
+		writeICSLine(&calendar, "END:VEVENT")
+	}
+	writeICSLine(&calendar, "END:VCALENDAR")
+	_, err := io.WriteString(w, calendar.String())
+	return err
+}
+
+func tripDescription(trip FlightToCompare, links []string) string {
+	var description strings.Builder
+	for _, flight := range []Outbound{trip.AbroadFlight, trip.ReturnFlight} {
+		departure := strings.Replace(flight.DepartureDate, "T", " ", 1)
+		if departureTime, err := flight.departureTime(); err == nil {
+			departure = departureTime.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(&description, "%s %s (%s) -> %s (%s), %s, %.2f %s\n",
+			flight.FlightNumber,
+			flight.DepartureAirport.Name, flight.DepartureAirport.IATACode,
+			flight.ArrivalAirport.Name, flight.ArrivalAirport.IATACode,
+			departure,
+			flight.Price.Value, flight.Price.CurrencyCode,
+		)
+	}
+	fmt.Fprintf(&description, "Total: %.2f PLN\n", trip.totalPrice())
+	for _, link := range links {
+		fmt.Fprintf(&description, "Book: %s\n", link)
+	}
+	return strings.TrimSuffix(description.String(), "\n")
+}
+
+// writeICSLine folds content lines longer than 75 octets, never splitting a UTF-8 character.
+func writeICSLine(calendar *strings.Builder, line string) {
+	limit := icsLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		calendar.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space
+		limit = icsLineLimit - 1
+	}
+	calendar.WriteString(line + "\r\n")
+}
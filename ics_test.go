@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_writeCalendar(t *testing.T) {
+	outbound := mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN").Outbound
+	outbound.ArrivalDate = "2024-10-04T15:30:00"
+	inbound := mockFare("ALC", "WMI", "2024-10-10T16:00:00", 86, "PLN").Outbound
+	inbound.ArrivalDate = "2024-10-10T20:05:00"
+	inbound.ArrivalAirport.Name = "Warsaw Modlin, Nowy Dwór Mazowiecki; Poland"
+	now := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+
+	var output bytes.Buffer
+	if err := writeCalendar(&output, []FlightToCompare{{outbound, inbound}}, now); err != nil {
+		t.Fatal(err)
+	}
+	calendar := output.String()
+	for _, line := range strings.Split(strings.TrimSuffix(calendar, "\r\n"), "\r\n") {
+		if len(line) > icsLineLimit {
+			t.Errorf("line longer than %d octets: %q", icsLineLimit, line)
+		}
+	}
+	unfolded := strings.ReplaceAll(calendar, "\r\n ", "")
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTAMP:20240901T080000Z\r\n",
+		"DTSTART:20241004T120000\r\n",
+		"DTEND:20241010T200500\r\n",
+		"STATUS:TENTATIVE\r\n",
+		"SUMMARY:WMI-ALC ALC-WMI 166.00 PLN\r\n",
+		`FRWMI WMI (WMI) -> ALC (ALC)\, 2024-10-04 12:00\, 80.00 PLN\n`,
+		`Warsaw Modlin\, Nowy Dwór Mazowiecki\; Poland (WMI)`,
+		`Total: 166.00 PLN\nBook: https://`,
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, calendar)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	textLogFormat = "text"
+	jsonLogFormat = "json"
+
+	redactedValue = "[REDACTED]"
+)
+
+var (
+	// telegramTokenPattern matches bot tokens like 123456789:AAE..., also inside bot API urls
+	telegramTokenPattern = regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`)
+	// telegramTargetPattern matches chat IDs in notifier names like telegram:123456
+	telegramTargetPattern = regexp.MustCompile(`telegram:-?\d+`)
+	// sensitiveLogKeys are attributes whose values are never logged
+	sensitiveLogKeys = map[string]bool{"chat": true, "chat_id": true, "token": true, "bot_token": true, "secret": true}
+
+	logSecrets = &secretSet{}
+)
+
+// secretSet holds values, like bot tokens and chat IDs from arguments, config and state,
+// replaced in every log record.
+type secretSet struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// addLogSecrets redacts the values from logs from now on, short values are ignored
+// so they do not redact unrelated numbers.
+func addLogSecrets(values ...string) {
+	logSecrets.mu.Lock()
+	defer logSecrets.mu.Unlock()
+	for _, value := range values {
+		if len(value) >= 4 && !slices.Contains(logSecrets.values, value) {
+			logSecrets.values = append(logSecrets.values, value)
+		}
+	}
+}
+
+func (s *secretSet) redact(text string) string {
+	text = telegramTokenPattern.ReplaceAllString(text, redactedValue)
+	text = telegramTargetPattern.ReplaceAllString(text, "telegram:"+redactedValue)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, secret := range s.values {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+	return text
+}
+
+// redactingHandler removes secrets from messages and attributes before the next handler formats them.
+type redactingHandler struct {
+	next    slog.Handler
+	secrets *secretSet
+}
+
+func (h redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.secrets.redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return redactingHandler{h.next.WithAttrs(redacted), h.secrets}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{h.next.WithGroup(name), h.secrets}
+}
+
+func (h redactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if sensitiveLogKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redactedValue)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.secrets.redact(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = h.redactAttr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, h.secrets.redact(err.Error()))
+		}
+		if stringer, ok := attr.Value.Any().(fmt.Stringer); ok {
+			return slog.String(attr.Key, h.secrets.redact(stringer.String()))
+		}
+	}
+	return attr
+}
+
+// newLogger writes records at level and above as text or JSON, with secrets redacted.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level: %s. available levels: debug, info, warn, error", level)
+	}
+	options := &slog.HandlerOptions{Level: minLevel}
+	var handler slog.Handler
+	switch format {
+	case textLogFormat:
+		handler = slog.NewTextHandler(w, options)
+	case jsonLogFormat:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s. available formats: %s, %s", format, textLogFormat, jsonLogFormat)
+	}
+	return slog.New(redactingHandler{handler, logSecrets}), nil
+}
+
+// setupLogging makes the redacting logger the default one, messages of the log package go through it too.
+func setupLogging(level, format string) error {
+	logger, err := newLogger(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// addConfigLogSecrets redacts credentials of the config. Urls of slack, discord and webhook
+// targets are credentials too, anyone knowing one can post messages.
+func addConfigLogSecrets() {
+	addLogSecrets(config.Bot.Token, config.Bot.Webhook.SecretToken)
+	addLogSecrets(config.Bot.AllowedChats...)
+	for _, target := range config.Targets {
+		addLogSecrets(target.BotToken, target.ChatID, target.Password, target.Secret, target.URL)
+	}
+}
+
+// fatal logs the error and exits, like log.Fatal.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
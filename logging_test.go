@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_newLogger_redaction(t *testing.T) {
+	token := "123456789:AAEhBOweik6ad9r_QXMENQjcrGbqCr4K-0M"
+	addLogSecrets("secret-chat-42")
+	var output bytes.Buffer
+	logger, err := newLogger(&output, "debug", jsonLogFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.With("token", token).Error("Could not call https://api.telegram.org/bot"+token+"/sendMessage",
+		"chat", "-100123",
+		"target", "telegram:-100123",
+		"error", errors.New("post https://api.telegram.org/bot"+token+"/getUpdates: timeout"),
+		slog.Group("request", "host", "api.telegram.org", "note", "sent to secret-chat-42"),
+		"status", 200,
+	)
+
+	line := output.String()
+	for _, secret := range []string{token, "100123", "secret-chat-42"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("log contains %s: %s", secret, line)
+		}
+	}
+	var record map[string]any
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("log is not JSON: %v\n%s", err, line)
+	}
+	want := map[string]any{
+		"level":  "ERROR",
+		"msg":    "Could not call https://api.telegram.org/bot[REDACTED]/sendMessage",
+		"token":  redactedValue,
+		"chat":   redactedValue,
+		"target": "telegram:[REDACTED]",
+		"error":  "post https://api.telegram.org/bot[REDACTED]/getUpdates: timeout",
+		"status": float64(200),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s, got: %v != want: %v", key, record[key], value)
+		}
+	}
+	if group, _ := record["request"].(map[string]any); group["note"] != "sent to [REDACTED]" || group["host"] != "api.telegram.org" {
+		t.Errorf("unexpected group: %v", record["request"])
+	}
+}
+
+func Test_newLogger_level(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := newLogger(&output, "warn", textLogFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("Fares fetched", "route", "WMI-ALC")
+	logger.Warn("Could not send charts", "route", "WMI-ALC")
+	if strings.Contains(output.String(), "Fares fetched") || !strings.Contains(output.String(), `level=WARN msg="Could not send charts" route=WMI-ALC`) {
+		t.Errorf("unexpected log: %s", output.String())
+	}
+
+	if _, err := newLogger(&output, "verbose", textLogFormat); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, err := newLogger(&output, "info", "xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func Test_addConfigLogSecrets(t *testing.T) {
+	defer func(previous Config) { config = previous }(config)
+	config = defaultConfig()
+	hook := "https://hooks.slack.com/services/T000/B000/XXXXSECRET"
+	config.Targets = []TargetConfig{{Type: slackTargetType, URL: hook}}
+	addConfigLogSecrets()
+	var output bytes.Buffer
+	logger, err := newLogger(&output, "info", textLogFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("Could not notify", "error", errors.New(`error sending request: Post "`+hook+`": timeout`))
+	if strings.Contains(output.String(), "XXXXSECRET") {
+		t.Errorf("log contains the slack url: %s", output.String())
+	}
+}
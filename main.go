@@ -4,134 +4,520 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
 	"net/url"
 	"os"
-	"sort"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	lookForwardInMonths = 5
-	offersPerMonth      = 5
 	chopinAirportCode   = "WAW"
 	modlinAirportCode   = "WMI"
 	alicanteAirportCode = "ALC"
+	ryanDateLayout      = "2006-01-02T15:04:05"
+
+	digestRunMode        = "digest"
+	priceDropsRunMode    = "price-drops"
+	thresholdsRunMode    = "thresholds"
+	botRunMode           = "bot"
+	webhookRunMode       = "webhook"
+	setWebhookRunMode    = "set-webhook"
+	deleteWebhookRunMode = "delete-webhook"
+	historyRunMode       = "history"
+	daemonRunMode        = "daemon"
+	serverRunMode        = "server"
 )
 
 var (
 	minTripDurationInDays = 3
 	maxTripDurationInDays = 15
 	chatId, botToken      string
+	runMode               = digestRunMode
+	outputFormat          string
+	outputPath            = stdoutOutput
+	calendarPath          string
+	htmlReportPath        string
+	dryRun, tableOutput   bool
+	config                = defaultConfig()
+	httpClient            = &http.Client{Timeout: 30 * time.Second, Transport: instrumentedTransport{http.DefaultTransport}}
+	telegramAPIURL        = "https://api.telegram.org"
+	ryanairAPIURL         = "https://www.ryanair.com"
+	nbpAPIURL             = "https://api.nbp.pl"
 )
 
 func main() {
 	if err := setOsArgs(); err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
-	now := time.Now()
-	currentYear, currentMonth, _ := now.Date()
-	currentLocation := now.Location()
-	startDate := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
-	endDate := startDate.AddDate(0, lookForwardInMonths, -1)
-
-	warsawToAlicanteFares, err := getWarsawToAlicanteFlights(startDate, endDate)
+	strategy, err := newRankingStrategy(config.Ranking)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
-	alicanteToWarsawFares, err := getAlicanteToWarsawFlights(startDate, endDate)
+	if isBotRunMode(runMode) {
+		if err := runBotMode(runMode, strategy); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if runMode == daemonRunMode {
+		if err := runDaemon(strategy); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if runMode == serverRunMode {
+		if err := runServer(strategy); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if err := run(time.Now(), runMode, strategy); err != nil {
+		fatal(err)
+	}
+}
+
+// run fetches fares once for every target and runs the mode, history only records the fares.
+func run(now time.Time, mode string, strategy RankingStrategy) error {
+	state, err := loadState(config.StatePath)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	// with exports and no notification targets trips are only exported
+	exporting := mode != historyRunMode && (outputFormat != "" || calendarPath != "" || htmlReportPath != "")
+	exportOnly := exporting && !dryRun && len(config.Targets) == 0 && chatId == "" && !hasSubscribers(state)
+	var targets []target
+	switch {
+	case dryRun:
+		targets, err = buildPreviewTargets(state)
+	case !exportOnly:
+		targets, err = buildRunTargets(state)
 	}
-	euroRate, err := getEuroRate()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	convertEURtoPLN(&alicanteToWarsawFares, euroRate)
-
-	flightsToCompare, err := getFlightsToCompare(warsawToAlicanteFares, alicanteToWarsawFares)
+	var wanted []Preferences
+	for _, target := range targets {
+		wanted = append(wanted, target.preferences)
+	}
+	if exporting {
+		wanted = append(wanted, Preferences{})
+	}
+	fares, euroRate, err := fetchLegFares(now, allLegs(wanted))
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	recordCheapestTrips(wanted, fares)
+	if mode == historyRunMode {
+		slog.Info("Fare history recorded", "legs", len(fares))
+		return nil
 	}
-	message := buildMessage(now, flightsToCompare)
-	sendMessageToTelegram(message, botToken, chatId)
+	if exporting {
+		if err := runExports(now, fares, euroRate, strategy); err != nil {
+			return err
+		}
+		if exportOnly {
+			return nil
+		}
+	}
+	switch {
+	case tableOutput:
+		err = printReportTables(now, fares, strategy, targets)
+	case mode == priceDropsRunMode:
+		err = runPriceDrops(now, fares, euroRate, targets, &state)
+	case mode == thresholdsRunMode:
+		err = runThresholds(now, fares, euroRate, strategy, targets, &state)
+	default:
+		// a dry run shows the digest even when it was already sent
+		err = runDigest(now, fares, euroRate, strategy, targets, &state, dryRun)
+	}
+	// a dry run leaves the state as it was, nothing was sent. Only what the run changed is saved,
+	// subscriptions may have changed through the bot meanwhile.
+	if config.StatePath != "" && !dryRun {
+		if err := updateState(config.StatePath, func(saved *State) error {
+			saved.Notified, saved.LastDigest = state.Notified, state.LastDigest
+			saved.forgetExpired(now, config.Dedupe)
+			return nil
+		}); err != nil {
+			slog.Error("Could not save state", "error", err)
+		}
+	}
+	return err
 }
 
-func setOsArgs() error {
-	if len(os.Args) <= 1 {
-		return errors.New("missing required arguments: chatId, botToken.\nadditional arguments are: minTripDurationInDays and maxTripDurationInDays")
-	} else {
-		if len(os.Args) > 4 {
-			min, err := strconv.Atoi(os.Args[3])
-			if err != nil {
-				return err
+// runDigest sends the full report to every target. Unless forced, a target is skipped
+// when every offer in its report was already sent to it recently at the same price.
+func runDigest(now time.Time, fares legFares, euroRate float64, strategy RankingStrategy, targets []target, state *State, force bool) error {
+	var errs []error
+	sent := 0
+	for _, target := range targets {
+		if target.preferences.quiet(now) {
+			slog.Info("Quiet hours, digest not sent", "target", target.notifier.Name())
+			continue
+		}
+		flightsToCompare, err := target.preferences.trips(fares)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		sections := buildReport(now, flightsToCompare, strategy)
+		var offers []FlightToCompare
+		for _, section := range sections {
+			offers = append(offers, section.Offers...)
+		}
+		if !force && len(offers) > 0 && len(state.filterNotified(target.key, offers, now, config.Dedupe)) == 0 {
+			slog.Info("Digest already sent, no offer changed", "target", target.notifier.Name())
+			continue
+		}
+		message, err := renderReport(sections, strategy, target.formatter, target.preferences.Language)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		if err := notify(target.notifier, "Ryanair digest", message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		state.markNotified(target.key, offers, now)
+		sent++
+		if photos, ok := target.notifier.(photoNotifier); ok && config.Charts.Enabled {
+			// the digest was delivered, charts failing do not fail it
+			if err := sendDigestCharts(photos, target.preferences, fares, offers); err != nil {
+				slog.Warn("Could not send charts", "target", target.notifier.Name(), "error", err)
 			}
-			max, err := strconv.Atoi(os.Args[4])
-			if err != nil {
-				return err
+		}
+		if documents, ok := target.notifier.(documentNotifier); ok && config.Attachments.Format != "" {
+			if err := sendTripsDocument(documents, now, rankedTrips(flightsToCompare, strategy), euroRate); err != nil {
+				slog.Warn("Could not send trips document", "target", target.notifier.Name(), "error", err)
 			}
-			if min <= 0 || max <= 0 {
-				return fmt.Errorf("one of optional args is wrong. integer greater than 0 needed. your args: %s, %s", os.Args[3], os.Args[4])
+		}
+	}
+	// a digest due during quiet hours of every target stays due
+	if sent > 0 {
+		state.LastDigest = now
+	}
+	return errors.Join(errs...)
+}
+
+func runPriceDrops(now time.Time, fares legFares, euroRate float64, targets []target, state *State) error {
+	var errs []error
+	for _, target := range targets {
+		if target.preferences.quiet(now) {
+			slog.Info("Quiet hours, price drops not sent", "target", target.notifier.Name())
+			continue
+		}
+		flightsToCompare, err := target.preferences.trips(fares)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		drops, err := getPriceDrops(now, flightsToCompare, euroRate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		// the limit applies to drops not sent yet, so drops already sent do not hide the next ones
+		var fresh []priceDrop
+		var trips []FlightToCompare
+		for _, drop := range drops {
+			if len(fresh) == config.Alerts.MaxPriceDrops {
+				break
+			}
+			if state.shouldNotify(target.key, drop.Trip, now, config.Dedupe) {
+				fresh = append(fresh, drop)
+				trips = append(trips, drop.Trip)
 			}
-			minTripDurationInDays = min
-			maxTripDurationInDays = max
 		}
-		chatId, botToken = os.Args[1], os.Args[2]
+		if len(fresh) == 0 {
+			slog.Info("No new price drops", "target", target.notifier.Name())
+			continue
+		}
+		message, err := renderTemplate("priceDrops", target.preferences.Language, target.formatter, priceDropsData{fresh})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		if err := notify(target.notifier, "Price drops", message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		state.markNotified(target.key, trips, now)
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func getWarsawToAlicanteFlights(startDate, endDate time.Time) ([]Fare, error) {
-	var modlinToAlicante FlightResponse
-	var chopinToAlicante FlightResponse
-	var flightsData []byte
+// runThresholds sends only trips matching alert rules, plus the full digest when its interval passed.
+func runThresholds(now time.Time, fares legFares, euroRate float64, strategy RankingStrategy, targets []target, state *State) error {
+	var errs []error
+	for _, target := range targets {
+		if target.preferences.quiet(now) {
+			slog.Info("Quiet hours, alerts not sent", "target", target.notifier.Name())
+			continue
+		}
+		flightsToCompare, err := target.preferences.trips(fares)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		var results []ruleMatches
+		for _, result := range evaluateRules(flightsToCompare, config.Thresholds.Rules, strategy, config.Thresholds.MaxMatchesPerRule) {
+			result.Trips = state.filterNotified(target.key, result.Trips, now, config.Dedupe)
+			if len(result.Trips) > 0 {
+				results = append(results, result)
+			}
+		}
+		if len(results) == 0 {
+			slog.Info("No new trips matching alert rules", "target", target.notifier.Name())
+			continue
+		}
+		message, err := renderTemplate("alerts", target.preferences.Language, target.formatter, alertsData{results})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		if err := notify(target.notifier, "Price alerts", message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", target.notifier.Name(), err))
+			continue
+		}
+		for _, result := range results {
+			state.markNotified(target.key, result.Trips, now)
+		}
+	}
 
-	flightsData, err := getRyanFlights(modlinAirportCode, alicanteAirportCode, startDate, endDate)
-	if err != nil {
-		return nil, fmt.Errorf("could not gather data from ryanair website.\n%v", err)
+	if config.Thresholds.DigestIntervalHours > 0 &&
+		state.digestDue(now, time.Duration(config.Thresholds.DigestIntervalHours)*time.Hour) {
+		errs = append(errs, runDigest(now, fares, euroRate, strategy, targets, state, true))
 	}
-	err = json.Unmarshal([]byte(flightsData), &modlinToAlicante)
+	return errors.Join(errs...)
+}
+
+// fetchFlightsToCompare fetches fares of the default routes, records them in history
+// and pairs them into trips priced in PLN.
+func fetchFlightsToCompare(now time.Time) (map[Bucket][]FlightToCompare, float64, error) {
+	fares, euroRate, err := fetchLegFares(now, Preferences{}.legs())
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+		return nil, 0, err
 	}
-	flightsData, err = getRyanFlights(chopinAirportCode, alicanteAirportCode, startDate, endDate)
+	flightsToCompare, err := Preferences{}.trips(fares)
 	if err != nil {
-		return nil, fmt.Errorf("could not gather data from ryanair website.\n%v", err)
+		return nil, 0, err
 	}
-	err = json.Unmarshal([]byte(flightsData), &chopinToAlicante)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+	return flightsToCompare, euroRate, nil
+}
+
+// getPriceDrops compares trips with their previous observation from history store, when it is configured,
+// and with previous prices reported by ryanair.
+func getPriceDrops(now time.Time, flightsToCompare map[Bucket][]FlightToCompare, euroRate float64) ([]priceDrop, error) {
+	var store *historyStore
+	if config.History.Path != "" {
+		var err error
+		store, err = openHistoryStore(config.History.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
 	}
-	return append(modlinToAlicante.Fares, chopinToAlicante.Fares...), nil
+	return findPriceDrops(flightsToCompare, previousPriceFromHistory(store, now, euroRate), config.Alerts.MinDropPercent), nil
 }
 
-func getAlicanteToWarsawFlights(startDate, endDate time.Time) ([]Fare, error) {
-	var alicanteToModlin FlightResponse
-	var alicanteToChopin FlightResponse
-	var flightsData []byte
-	flightsData, err := getRyanFlights(alicanteAirportCode, modlinAirportCode, startDate, endDate)
-	if err != nil {
-		return nil, fmt.Errorf("could not gather data from ryanair website.\n%v", err)
+func setOsArgs() error {
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to JSON config file")
+	ranking := flags.String("ranking", "", "ranking strategy, overrides the one from config file")
+	selection := flags.String("selection", "", "top offers selection mode, overrides the one from config file")
+	mode := flags.String("mode", digestRunMode, "run mode: digest, price-drops, thresholds, history, daemon, server, bot, webhook, set-webhook or delete-webhook")
+	historyPath := flags.String("history", "", "path to fare history store, overrides the one from config file")
+	bucketing := flags.String("bucketing", "", "report bucketing: departure-month, return-month or iso-week")
+	format := flags.String("format", "", "also write paired trips as json, csv or ndjson")
+	output := flags.String("output", stdoutOutput, "file the -format output is written to, - for standard output")
+	calendar := flags.String("ics", "", "also write top trips of the digest to this iCalendar file")
+	htmlReport := flags.String("html", "", "also write a self-contained HTML report with a price calendar to this file")
+	logLevel := flags.String("log-level", "info", "log level: debug, info, warn or error")
+	logFormat := flags.String("log-format", textLogFormat, "log format: text or json")
+	flags.BoolVar(&dryRun, "dry-run", false, "print reports to standard output instead of sending them, credentials are optional")
+	flags.BoolVar(&tableOutput, "table", false, "with -dry-run, print the digest as an aligned table")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		return err
 	}
-	err = json.Unmarshal([]byte(flightsData), &alicanteToModlin)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+	// logging is set up first, so errors of the config below are logged redacted and in the chosen format
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		return err
 	}
-	flightsData, err = getRyanFlights(alicanteAirportCode, chopinAirportCode, startDate, endDate)
-	if err != nil {
-		return nil, fmt.Errorf("could not gather data from ryanair website.\n%v", err)
+	if args := flags.Args(); len(args) >= 2 {
+		addLogSecrets(args[0], args[1])
+	}
+	if *configPath != "" {
+		loaded, err := loadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		config = loaded
+	}
+	addConfigLogSecrets()
+	if *ranking != "" {
+		config.Ranking.Strategy = *ranking
+	}
+	if *selection != "" {
+		config.Selection.Mode = *selection
+	}
+	switch *mode {
+	case digestRunMode, priceDropsRunMode, thresholdsRunMode, historyRunMode, daemonRunMode, serverRunMode,
+		botRunMode, webhookRunMode, setWebhookRunMode, deleteWebhookRunMode:
+		runMode = *mode
+	default:
+		return fmt.Errorf("unknown run mode: %s. available modes: %s", *mode, strings.Join([]string{
+			digestRunMode,
+			priceDropsRunMode,
+			thresholdsRunMode,
+			historyRunMode,
+			daemonRunMode,
+			serverRunMode,
+			botRunMode,
+			webhookRunMode,
+			setWebhookRunMode,
+			deleteWebhookRunMode,
+		}, ", "))
+	}
+	if *historyPath != "" {
+		config.History.Path = *historyPath
+	}
+	if *bucketing != "" {
+		config.Bucketing = *bucketing
+	}
+	if *format != "" {
+		if err := validateOutputFormat(*format); err != nil {
+			return err
+		}
+		outputFormat, outputPath = *format, *output
+	}
+	calendarPath, htmlReportPath = *calendar, *htmlReport
+	if dryRun && (isBotRunMode(runMode) || runMode == serverRunMode) {
+		return fmt.Errorf("-dry-run is not available in %s mode", runMode)
+	}
+	if tableOutput && (!dryRun || runMode != digestRunMode) {
+		return errors.New("-table needs -dry-run in digest mode")
+	}
+	if err := validateSelectionConfig(config.Selection); err != nil {
+		return err
 	}
-	err = json.Unmarshal([]byte(flightsData), &alicanteToChopin)
+	if err := validateBucketing(config.Bucketing); err != nil {
+		return err
+	}
+	if config.Attachments.Format != "" {
+		if err := validateOutputFormat(config.Attachments.Format); err != nil {
+			return fmt.Errorf("attachments: %v", err)
+		}
+	}
+	if err := validateTargets(config.Targets); err != nil {
+		return err
+	}
+	if err := validateAlertRules(config.Thresholds.Rules); err != nil {
+		return err
+	}
+	if _, err := newReportTemplate(config.Templates.Language, plainFormatter{}); err != nil {
+		return err
+	}
+	if runMode == daemonRunMode {
+		if _, err := newScheduledJobs(config.Daemon.Jobs); err != nil {
+			return err
+		}
+	}
+	if runsMode(historyRunMode) && config.History.Path == "" {
+		return errors.New("history mode needs history.path in config file or -history")
+	}
+	if runsMode(thresholdsRunMode) && config.Thresholds.DigestIntervalHours > 0 && config.StatePath == "" {
+		return errors.New("thresholds.digestIntervalHours needs statePath in config file")
+	}
+	if config.Dedupe.Enabled && config.StatePath == "" {
+		return errors.New("dedupe needs statePath in config file")
+	}
+	args := flags.Args()
+	if len(args) < 2 && len(config.Targets) == 0 && outputFormat == "" && calendarPath == "" && htmlReportPath == "" && !dryRun && runMode != serverRunMode && config.Bot.Token == "" {
+		return errors.New("missing required arguments: chatId, botToken.\nadditional arguments are: minTripDurationInDays and maxTripDurationInDays")
+	}
+	if len(args) > 3 {
+		min, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+		max, err := strconv.Atoi(args[3])
+		if err != nil {
+			return err
+		}
+		if min <= 0 || max <= 0 {
+			return fmt.Errorf("one of optional args is wrong. integer greater than 0 needed. your args: %s, %s", args[2], args[3])
+		}
+		minTripDurationInDays = min
+		maxTripDurationInDays = max
+	}
+	if len(args) >= 2 {
+		chatId, botToken = args[0], args[1]
+	}
+	return nil
+}
+
+// runsMode is true when the run mode or any daemon job runs the mode.
+func runsMode(mode string) bool {
+	if runMode == daemonRunMode {
+		return slices.ContainsFunc(config.Daemon.Jobs, func(job DaemonJob) bool { return job.Mode == mode })
+	}
+	return runMode == mode
+}
+
+// getFares fetches one way fares between every pair of departure and arrival airports.
+func getFares(departureAirportCodes, arrivalAirportCodes []string, startDate, endDate time.Time) ([]Fare, error) {
+	var fares []Fare
+	for _, departure := range departureAirportCodes {
+		for _, arrival := range arrivalAirportCodes {
+			key := fmt.Sprintf("ryanair:%s-%s:%s:%s", departure, arrival, startDate.Format(time.DateOnly), endDate.Format(time.DateOnly))
+			// fares served from the cache were counted when they were fetched
+			fetched := false
+			flightsData, err := upstreamCache.get(key, func() ([]byte, error) {
+				fetched = true
+				return getRyanFlights(departure, arrival, startDate, endDate)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not gather data from ryanair website.\n%v", err)
+			}
+			var response FlightResponse
+			if err := json.Unmarshal(flightsData, &response); err != nil {
+				return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+			}
+			if fetched {
+				faresFetched.add(float64(len(response.Fares)), departure+"-"+arrival)
+				slog.Debug("Fares fetched", "route", departure+"-"+arrival, "fares", len(response.Fares))
+			}
+			fares = append(fares, response.Fares...)
+		}
+	}
+	return fares, nil
+}
+
+// recordFareHistory stores fetched fares in their original currency and drops observations past retention.
+func recordFareHistory(now time.Time, fares ...[]Fare) error {
+	store, err := openHistoryStore(config.History.Path)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON response: %v", err)
+		return err
 	}
-	return append(alicanteToModlin.Fares, alicanteToChopin.Fares...), nil
+	defer store.Close()
+	for _, f := range fares {
+		if err := store.record(f, now); err != nil {
+			return fmt.Errorf("could not record fare history: %v", err)
+		}
+	}
+	if config.History.RetentionDays > 0 {
+		if _, err := store.prune(now.AddDate(0, 0, -config.History.RetentionDays)); err != nil {
+			return fmt.Errorf("could not prune fare history: %v", err)
+		}
+	}
+	return nil
 }
 
 func getRyanFlights(
@@ -141,20 +527,21 @@ func getRyanFlights(
 	endDate time.Time,
 ) ([]byte, error) {
 	url := fmt.Sprintf(
-		"https://www.ryanair.com/api/farfnd/v4/oneWayFares?departureAirportIataCode=%s&outboundDepartureDateFrom=%s&market=pl-pl&adultPaxCount=1&arrivalAirportIataCode=%s&searchMode=ALL&outboundDepartureDateTo=%s&outboundDepartureDaysOfWeek=MONDAY,TUESDAY,WEDNESDAY,THURSDAY,FRIDAY,SATURDAY,SUNDAY&outboundDepartureTimeFrom=00:00&outboundDepartureTimeTo=23:59",
+		"%s/api/farfnd/v4/oneWayFares?departureAirportIataCode=%s&outboundDepartureDateFrom=%s&market=pl-pl&adultPaxCount=1&arrivalAirportIataCode=%s&searchMode=ALL&outboundDepartureDateTo=%s&outboundDepartureDaysOfWeek=MONDAY,TUESDAY,WEDNESDAY,THURSDAY,FRIDAY,SATURDAY,SUNDAY&outboundDepartureTimeFrom=00:00&outboundDepartureTimeTo=23:59",
+		ryanairAPIURL,
 		departureAirportCode,
 		startDate.Format(time.DateOnly),
 		arrivalAirportCode,
 		endDate.Format(time.DateOnly),
 	)
-	resp, err := http.Get(url)
+	resp, err := httpClient.Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error: received non-200 response code: %d\nurl: %s", resp.StatusCode, url)
+		return nil, fmt.Errorf("error: received non-200 response code: %d for route %s-%s", resp.StatusCode, departureAirportCode, arrivalAirportCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -174,121 +561,137 @@ func convertEURtoPLN(fares *[]Fare, euroRate float64) {
 		(*fares)[i].Summary.Price.CurrencyCode = "PLN"
 		(*fares)[i].Outbound.Price.CurrencySymbol = "zł"
 		(*fares)[i].Summary.Price.CurrencySymbol = "zł"
+		if previousPrice := (*fares)[i].Outbound.PreviousPrice; previousPrice != nil {
+			converted := math.Round(*previousPrice*euroRate*100) / 100
+			(*fares)[i].Outbound.PreviousPrice = &converted
+		}
+		if previousPrice := (*fares)[i].Summary.PreviousPrice; previousPrice != nil {
+			converted := math.Round(*previousPrice*euroRate*100) / 100
+			(*fares)[i].Summary.PreviousPrice = &converted
+		}
 	}
 }
 
 func getEuroRate() (float64, error) {
-	client := http.Client{}
-	req, err := http.NewRequest("GET", "https://api.nbp.pl/api/exchangerates/rates/a/eur/last/1/?format=json", bytes.NewBuffer([]byte{}))
+	body, err := upstreamCache.get("nbp:eur", fetchEuroRate)
 	if err != nil {
 		return 0, err
 	}
+
+	var exchangeRates ExchangeRates
+	err = json.Unmarshal([]byte(body), &exchangeRates)
+	if err != nil {
+		return 0, fmt.Errorf("error unmarshalling JSON: %v", err)
+	}
+
+	return exchangeRates.Rates[0].Mid, nil
+}
+
+func fetchEuroRate() ([]byte, error) {
+	req, err := http.NewRequest("GET", nbpAPIURL+"/api/exchangerates/rates/a/eur/last/1/?format=json", bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Add("User-Agent", `Mozilla/5.0 (Macintosh; Intel Mac OS X 10_7_5) AppleWebKit/537.11 (KHTML, like Gecko) Chrome/23.0.1271.64 Safari/537.11`)
 
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("getEuroRate() Error: received non-200 response code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("getEuroRate() Error: received non-200 response code: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return 0, fmt.Errorf("error reading the response body: %v", err)
+		return nil, fmt.Errorf("error reading the response body: %v", err)
 	}
+	return body, nil
+}
 
-	var exchangeRates ExchangeRates
-	err = json.Unmarshal([]byte(body), &exchangeRates)
-	if err != nil {
-		return 0, fmt.Errorf("error unmarshalling JSON: %v", err)
-	}
+// searchHorizon returns the first day of the current month and the last day of the looked forward months.
+func searchHorizon(now time.Time) (time.Time, time.Time) {
+	currentYear, currentMonth, _ := now.Date()
+	startDate := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, now.Location())
+	return startDate, startDate.AddDate(0, config.LookForwardInMonths, -1)
+}
 
-	return exchangeRates.Rates[0].Mid, nil
+func getFlightsToCompare(warsawToAlicanteFares, alicanteToWarsawFares []Fare) (map[Bucket][]FlightToCompare, error) {
+	return pairTrips(warsawToAlicanteFares, alicanteToWarsawFares, minTripDurationInDays, maxTripDurationInDays)
 }
 
-func getFlightsToCompare(warsawToAlicanteFares, alicanteToWarsawFares []Fare) (map[time.Month][]FlightToCompare, error) {
-	flights := make(map[time.Month][]FlightToCompare)
-	for _, wawToAlc := range warsawToAlicanteFares {
-		for _, alcToWaw := range alicanteToWarsawFares {
-			departureDate, err := time.Parse("2006-01-02T15:04:05", wawToAlc.Outbound.DepartureDate)
+// pairTrips pairs every outbound fare with return fares leaving between minDays and maxDays later.
+func pairTrips(outboundFares, returnFares []Fare, minDays, maxDays int) (map[Bucket][]FlightToCompare, error) {
+	flights := make(map[Bucket][]FlightToCompare)
+	for _, outbound := range outboundFares {
+		for _, inbound := range returnFares {
+			departureDate, err := time.Parse(ryanDateLayout, outbound.Outbound.DepartureDate)
 			if err != nil {
 				return nil, err
 			}
-			returnDate, err := time.Parse("2006-01-02T15:04:05", alcToWaw.Outbound.DepartureDate)
+			returnDate, err := time.Parse(ryanDateLayout, inbound.Outbound.DepartureDate)
 			if err != nil {
 				return nil, err
 			}
 			if departureDate.Before(returnDate) &&
-				returnDate.Sub(departureDate) < time.Hour*24*time.Duration(maxTripDurationInDays) &&
-				returnDate.Sub(departureDate) > time.Hour*24*time.Duration(minTripDurationInDays) {
+				returnDate.Sub(departureDate) < time.Hour*24*time.Duration(maxDays) &&
+				returnDate.Sub(departureDate) > time.Hour*24*time.Duration(minDays) {
 
-				flights[departureDate.Month()] = append(
-					flights[departureDate.Month()], FlightToCompare{wawToAlc.Outbound, alcToWaw.Outbound})
+				bucket := tripBucket(departureDate, returnDate, config.Bucketing)
+				flights[bucket] = append(flights[bucket], FlightToCompare{outbound.Outbound, inbound.Outbound})
 			}
 		}
 	}
 	return flights, nil
 }
 
-func buildMessage(now time.Time, flightsToCompare map[time.Month][]FlightToCompare) bytes.Buffer {
-	upcomingMonths := make([]time.Month, lookForwardInMonths)
-	for i := 0; i < lookForwardInMonths; i++ {
-		upcomingMonths[i] = now.AddDate(0, i, -now.Day()+1).Month()
-	}
-
-	var message bytes.Buffer
-	for _, month := range upcomingMonths {
-		sort.Slice(flightsToCompare[month], func(i, j int) bool {
-			priceSummary := flightsToCompare[month][i].AbroadFlight.Price.Value + flightsToCompare[month][i].ReturnFlight.Price.Value
-			nextPriceSummary := flightsToCompare[month][j].AbroadFlight.Price.Value + flightsToCompare[month][j].ReturnFlight.Price.Value
-			return priceSummary < nextPriceSummary
-		})
-		message.WriteString(month.String())
-		message.WriteString("\n")
-		if len(flightsToCompare[month]) > 0 {
-			for _, trip := range flightsToCompare[month][:offersPerMonth] {
-				message.WriteString(fmt.Sprintf("%s ---> %s ", trip.AbroadFlight.DepartureAirport.Name, trip.AbroadFlight.ArrivalAirport.Name))
-				message.WriteString(fmt.Sprintf("%s ", strings.Replace(trip.AbroadFlight.DepartureDate, "T", " ", 1)))
-				message.WriteString(fmt.Sprintf("%s%s\n", strconv.FormatFloat(trip.AbroadFlight.Price.Value, 'f', 2, 64), trip.AbroadFlight.Price.CurrencySymbol))
-				message.WriteString(fmt.Sprintf("%s ---> %s ", trip.ReturnFlight.DepartureAirport.Name, trip.ReturnFlight.ArrivalAirport.Name))
-				message.WriteString(fmt.Sprintf("%s ", strings.Replace(trip.ReturnFlight.DepartureDate, "T", " ", 1)))
-				message.WriteString(fmt.Sprintf("%s%s\n", strconv.FormatFloat(trip.ReturnFlight.Price.Value, 'f', 2, 64), trip.ReturnFlight.Price.CurrencySymbol))
-				message.WriteString(fmt.Sprintf("Razem: %szł\n", strconv.FormatFloat(trip.AbroadFlight.Price.Value+trip.ReturnFlight.Price.Value, 'f', 2, 64)))
-				message.WriteString("\n")
-			}
-		} else {
-			message.WriteString("No flights for this month\n")
-		}
-		message.WriteString("------------------------------------------\n")
-	}
-	return message
+// reportSection holds the offers of one bucket and how many matching trips were left out.
+type reportSection struct {
+	Bucket Bucket
+	Offers []FlightToCompare
+	More   int
 }
 
-func sendMessageToTelegram(message bytes.Buffer, botToken, chatId string) error {
-	u := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
-	data := url.Values{}
-	data.Set("chat_id", chatId)
-	data.Set("text", message.String())
-	req, err := http.NewRequest("POST", u, strings.NewReader(data.Encode()))
-	if err != nil {
-		return err
+// buildReport ranks trips of every bucket in the horizon and selects offers to show.
+func buildReport(now time.Time, flightsToCompare map[Bucket][]FlightToCompare, strategy RankingStrategy) []reportSection {
+	var sections []reportSection
+	startDate, endDate := searchHorizon(now)
+	for _, bucket := range reportBuckets(startDate, endDate, flightsToCompare) {
+		trips := flightsToCompare[bucket]
+		rankTrips(trips, strategy)
+		offers := selectOffers(trips, config.Limits.forBucket(bucket), config.Selection)
+		sections = append(sections, reportSection{bucket, offers, len(trips) - len(offers)})
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return sections
+}
 
-	client := http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending request: %v", err)
-	}
-	defer resp.Body.Close()
+func buildMessage(now time.Time, flightsToCompare map[Bucket][]FlightToCompare, strategy RankingStrategy) (bytes.Buffer, error) {
+	return renderReport(buildReport(now, flightsToCompare, strategy), strategy, plainFormatter{}, "")
+}
+
+// renderReport renders the digest template in the language, empty means templates.language.
+func renderReport(sections []reportSection, strategy RankingStrategy, f messageFormatter, language string) (bytes.Buffer, error) {
+	return renderTemplate("digest", language, f, digestData{strategy.Name(), sections, config.Limits.ShowMore})
+}
 
-	if resp.StatusCode == http.StatusOK {
-		log.Print("Message sent successfully!")
-	} else {
-		return fmt.Errorf("failed to send message. Status code: %d", resp.StatusCode)
+// sendMessageToTelegram sends the message in order, split into parts when it is longer than telegram allows.
+// Empty parseMode sends plain text.
+func sendMessageToTelegram(message bytes.Buffer, botToken, chatId, parseMode string) error {
+	parts := splitTelegramMessage(message.String())
+	for _, part := range parts {
+		data := url.Values{}
+		data.Set("chat_id", chatId)
+		data.Set("text", part)
+		if parseMode != plainParseMode {
+			data.Set("parse_mode", parseMode)
+			data.Set("link_preview_options", `{"is_disabled":true}`)
+		}
+		if _, err := postTelegramForm(botToken, "sendMessage", data); err != nil {
+			return err
+		}
 	}
+	slog.Info("Message sent", "parts", len(parts))
 	return nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -106,7 +108,7 @@ func Test_getFlightsToCompare(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			keys := []time.Month{}
+			keys := []Bucket{}
 			got := 0
 			for key := range flights {
 				keys = append(keys, key)
@@ -121,6 +123,49 @@ func Test_getFlightsToCompare(t *testing.T) {
 	}
 }
 
+func Test_buildMessage(t *testing.T) {
+	now := time.Date(2024, time.October, 17, 12, 0, 0, 0, time.UTC)
+	flights := map[Bucket][]FlightToCompare{
+		{Year: 2024, Month: time.October}: {
+			getMockTrip("2024-10-20T12:00:00", 100, "2024-10-25T12:00:00", 100),
+			getMockTrip("2024-10-21T12:00:00", 50, "2024-10-25T12:00:00", 100),
+			getMockTrip("2024-10-22T12:00:00", 70, "2024-10-25T12:00:00", 100),
+		},
+	}
+	tests := []struct {
+		name   string
+		limits LimitsConfig
+		want   []string
+	}{
+		{
+			name:   "less trips than offers per month",
+			limits: LimitsConfig{OffersPerMonth: 5},
+			want:   []string{"Razem: 150,00 zł", "Razem: 170,00 zł", "Razem: 200,00 zł", "Brak lotów w tym miesiącu"},
+		},
+		{
+			name:   "per month limit with and more summary",
+			limits: LimitsConfig{OffersPerMonth: 5, PerMonth: map[string]int{"2024-10": 1}, ShowMore: true},
+			want:   []string{"Razem: 150,00 zł", "...i 2 więcej"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config = defaultConfig()
+			config.Limits = test.limits
+			defer func() { config = defaultConfig() }()
+			message, err := buildMessage(now, flights, totalPriceStrategy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(message.String(), want) {
+					t.Errorf("message does not contain %q:\n%s", want, message.String())
+				}
+			}
+		})
+	}
+}
+
 func getMockAlcToWawFares() []Fare {
 	return []Fare{
 		{
@@ -1372,3 +1417,30 @@ func getWantedAlcToWawFares_3() []Fare {
 		},
 	}
 }
+
+func Test_runDigest_lastDigest(t *testing.T) {
+	defer func(previous io.Writer) { previewOutput = previous }(previewOutput)
+	previewOutput = io.Discard
+	now := time.Date(2024, time.October, 1, 23, 0, 0, 0, time.UTC)
+	quiet := Preferences{QuietHours: &QuietHours{From: "22:00", To: "07:00", Timezone: "UTC"}}
+	tests := []struct {
+		name    string
+		targets []target
+		want    time.Time
+	}{
+		{"every target in quiet hours", []target{{"a", stdoutNotifier{"a"}, quiet, plainFormatter{}}}, time.Time{}},
+		{"one target notified", []target{
+			{"a", stdoutNotifier{"a"}, quiet, plainFormatter{}},
+			{"b", stdoutNotifier{"b"}, Preferences{}, plainFormatter{}},
+		}, now},
+	}
+	for _, test := range tests {
+		var state State
+		if err := runDigest(now, legFares{}, 4.3, totalPriceStrategy{}, test.targets, &state, true); err != nil {
+			t.Fatal(err)
+		}
+		if !state.LastDigest.Equal(test.want) {
+			t.Errorf("%s, last digest got: %v != want: %v", test.name, state.LastDigest, test.want)
+		}
+	}
+}
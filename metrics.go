@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsPrefix = "scrap_ryan_"
+
+var (
+	upstreamRequests = newMetricVec("upstream_requests_total", "counter",
+		"Requests to ryanair, NBP and telegram by host and status, error when no response came.", "host", "status")
+	upstreamDuration = newHistogramVec("upstream_request_duration_seconds",
+		"Latency of requests to ryanair, NBP and telegram by host and status.",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}, "host", "status")
+	cacheRequests = newMetricVec("cache_requests_total", "counter",
+		"Lookups of the upstream response cache by result, hit or miss.", "result")
+	faresFetched = newMetricVec("fares_fetched_total", "counter",
+		"Fares returned by ryanair by route.", "route")
+	cheapestTrip = newMetricVec("cheapest_trip_total_pln", "gauge",
+		"Total price of the cheapest trip of the last run by outbound route and departure month.", "route", "month")
+	notifications = newMetricVec("notifications_total", "counter",
+		"Messages sent to targets by notifier type and result, sent or failed.", "notifier", "result")
+	lastSuccess = newMetricVec("last_success_timestamp_seconds", "gauge",
+		"Unix time of the last successful run of a daemon job.", "job")
+
+	metricFamilies = []interface{ write(w io.Writer) }{
+		upstreamRequests, upstreamDuration, cacheRequests, faresFetched, cheapestTrip, notifications, lastSuccess,
+	}
+)
+
+// metricVec is a counter or gauge with values for every combination of label values.
+type metricVec struct {
+	name, kind, help string
+	labels           []string
+	mu               sync.Mutex
+	values           map[string]float64
+}
+
+func newMetricVec(name, kind, help string, labels ...string) *metricVec {
+	return &metricVec{name: metricsPrefix + name, kind: kind, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (m *metricVec) add(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[strings.Join(labelValues, "\xff")] += value
+}
+
+func (m *metricVec) set(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[strings.Join(labelValues, "\xff")] = value
+}
+
+func (m *metricVec) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values = make(map[string]float64)
+}
+
+func (m *metricVec) get(labelValues ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[strings.Join(labelValues, "\xff")]
+}
+
+func (m *metricVec) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	for _, key := range sortedKeys(m.values) {
+		fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, key, ""), formatMetricValue(m.values[key]))
+	}
+}
+
+// histogramVec counts observations in cumulative buckets for every combination of label values.
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: metricsPrefix + name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, formatMetricValue(bound)), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatMetricValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), series.count)
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels formats label names with values joined in key, le is the bucket bound of histograms.
+func formatLabels(names []string, key, le string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", names[i], value))
+		}
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=%q", le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// writeMetrics writes every metric in the Prometheus text format, with the cache hit ratio
+// computed from cache lookups.
+func writeMetrics(w io.Writer) {
+	for _, family := range metricFamilies {
+		family.write(w)
+	}
+	hits, misses := cacheRequests.get("hit"), cacheRequests.get("miss")
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = hits / (hits + misses)
+	}
+	fmt.Fprintf(w, "# HELP %scache_hit_ratio Share of upstream response cache lookups answered from cache.\n", metricsPrefix)
+	fmt.Fprintf(w, "# TYPE %scache_hit_ratio gauge\n%scache_hit_ratio %s\n", metricsPrefix, metricsPrefix, formatMetricValue(ratio))
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w)
+}
+
+// instrumentedTransport counts upstream requests and their latency by host and status.
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	duration := time.Since(start)
+	upstreamRequests.add(1, req.URL.Host, status)
+	upstreamDuration.observe(duration.Seconds(), req.URL.Host, status)
+	// paths are not logged, telegram ones contain the bot token
+	slog.Debug("Upstream request", "method", req.Method, "host", req.URL.Host, "status", status, "duration", duration)
+	return resp, err
+}
+
+// recordCheapestTrips sets the cheapest trip total of every route and departure month
+// wanted by any of the preferences.
+func recordCheapestTrips(wanted []Preferences, fares legFares) {
+	cheapest := make(map[[2]string]float64)
+	for _, preferences := range wanted {
+		flightsToCompare, err := preferences.trips(fares)
+		if err != nil {
+			continue
+		}
+		for _, trips := range flightsToCompare {
+			for _, trip := range trips {
+				departure, err := trip.AbroadFlight.departureTime()
+				if err != nil {
+					continue
+				}
+				key := [2]string{trip.route(), departure.Format("2006-01")}
+				if total, ok := cheapest[key]; !ok || trip.totalPrice() < total {
+					cheapest[key] = trip.totalPrice()
+				}
+			}
+		}
+	}
+	cheapestTrip.reset()
+	for key, total := range cheapest {
+		cheapestTrip.set(total, key[0], key[1])
+	}
+}
+
+// notify sends the message to the notifier and counts it as sent or failed.
+func notify(notifier Notifier, subject string, message bytes.Buffer) error {
+	kind, _, _ := strings.Cut(notifier.Name(), ":")
+	if err := notifier.Notify(subject, message); err != nil {
+		notifications.add(1, kind, "failed")
+		return err
+	}
+	notifications.add(1, kind, "sent")
+	return nil
+}
+
+// serveMetrics serves /metrics on addr until ctx is done.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", metricsHandler)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: serverReadHeaderTimeout}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	slog.Info("Serving metrics", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("Metrics server failed", "error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_metricVec_write(t *testing.T) {
+	requests := newMetricVec("test_requests_total", "counter", "Test requests.", "host", "status")
+	requests.add(1, "www.ryanair.com", "200")
+	requests.add(2, "www.ryanair.com", "200")
+	requests.add(1, "api.nbp.pl", `5"00`)
+	latency := newHistogramVec("test_duration_seconds", "Test latency.", []float64{0.5, 1}, "host")
+	latency.observe(0.25, "api.nbp.pl")
+	latency.observe(0.75, "api.nbp.pl")
+
+	var output bytes.Buffer
+	requests.write(&output)
+	latency.write(&output)
+	want := `# HELP scrap_ryan_test_requests_total Test requests.
+# TYPE scrap_ryan_test_requests_total counter
+scrap_ryan_test_requests_total{host="api.nbp.pl",status="5\"00"} 1
+scrap_ryan_test_requests_total{host="www.ryanair.com",status="200"} 3
+# HELP scrap_ryan_test_duration_seconds Test latency.
+# TYPE scrap_ryan_test_duration_seconds histogram
+scrap_ryan_test_duration_seconds_bucket{host="api.nbp.pl",le="0.5"} 1
+scrap_ryan_test_duration_seconds_bucket{host="api.nbp.pl",le="1"} 2
+scrap_ryan_test_duration_seconds_bucket{host="api.nbp.pl",le="+Inf"} 2
+scrap_ryan_test_duration_seconds_sum{host="api.nbp.pl"} 1
+scrap_ryan_test_duration_seconds_count{host="api.nbp.pl"} 2
+`
+	if output.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", output.String(), want)
+	}
+}
+
+func Test_instrumentedTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := upstreamRequests.get(host, "418"); got != 1 {
+		t.Errorf("requests of %s, got: %v != want: 1", host, got)
+	}
+	var output bytes.Buffer
+	writeMetrics(&output)
+	for _, want := range []string{
+		`scrap_ryan_upstream_request_duration_seconds_count{host="` + host + `",status="418"} 1`,
+		"# TYPE scrap_ryan_cache_hit_ratio gauge",
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, output.String())
+		}
+	}
+}
+
+func Test_recordCheapestTrips(t *testing.T) {
+	fares := legFares{
+		{"WMI", "ALC"}: {
+			mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN"),
+			mockFare("WMI", "ALC", "2024-11-04T12:00:00", 150, "PLN"),
+		},
+		{"ALC", "WMI"}: {
+			mockFare("ALC", "WMI", "2024-10-10T12:00:00", 86, "PLN"),
+			mockFare("ALC", "WMI", "2024-10-12T12:00:00", 40, "PLN"),
+			mockFare("ALC", "WMI", "2024-11-10T12:00:00", 50, "PLN"),
+		},
+	}
+	cheapestTrip.set(1, "WAW-ALC", "2024-01")
+	recordCheapestTrips([]Preferences{{Routes: []string{"WMI-ALC"}}}, fares)
+	if got := cheapestTrip.get("WMI-ALC", "2024-10"); got != 120 {
+		t.Errorf("october, got: %v != want: %v", got, 120)
+	}
+	if got := cheapestTrip.get("WMI-ALC", "2024-11"); got != 200 {
+		t.Errorf("november, got: %v != want: %v", got, 200)
+	}
+	if got := cheapestTrip.get("WAW-ALC", "2024-01"); got != 0 {
+		t.Errorf("route of a previous run was kept: %v", got)
+	}
+}
+
+func Test_notify(t *testing.T) {
+	sent, failed := notifications.get("test", "sent"), notifications.get("test", "failed")
+	notify(fakeNotifier{"test:1", nil}, "Ryanair digest", bytes.Buffer{})
+	notify(fakeNotifier{"test:2", errors.New("unavailable")}, "Ryanair digest", bytes.Buffer{})
+	if notifications.get("test", "sent") != sent+1 || notifications.get("test", "failed") != failed+1 {
+		t.Errorf("notifications were not counted by notifier type")
+	}
+}
+
+type fakeNotifier struct {
+	name string
+	err  error
+}
+
+func (n fakeNotifier) Name() string { return n.name }
+
+func (n fakeNotifier) Notify(string, bytes.Buffer) error { return n.err }
+
+func Test_getFares_countsFetchedFaresOnce(t *testing.T) {
+	_, closeUpstream := fakeUpstream(t, nil)
+	defer closeUpstream()
+	defer func(previous *responseCache) { upstreamCache = previous }(upstreamCache)
+	upstreamCache = newResponseCache(time.Minute)
+	before := faresFetched.get("ALC-WMI")
+	start := time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if _, err := getFares([]string{"ALC"}, []string{"WMI"}, start, start.AddDate(0, 1, -1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := faresFetched.get("ALC-WMI") - before; got != 2 {
+		t.Errorf("fares fetched, got: %v != want: %v", got, 2)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	telegramTargetType = "telegram"
+	slackTargetType    = "slack"
+	discordTargetType  = "discord"
+	emailTargetType    = "email"
+	webhookTargetType  = "webhook"
+
+	discordMessageLimit = 2000
+	webhookSignature    = "X-Signature-256"
+)
+
+// Notifier delivers a rendered message to a single target.
+type Notifier interface {
+	Name() string
+	Notify(subject string, message bytes.Buffer) error
+}
+
+// photoNotifier is a Notifier able to deliver charts after the message.
+type photoNotifier interface {
+	NotifyPhotos(photos []chartImage) error
+}
+
+// documentNotifier is a Notifier able to deliver a file after the message.
+type documentNotifier interface {
+	NotifyDocument(name, caption string, content []byte) error
+}
+
+// target is a subscriber: a notifier with preferences for its reports
+// and the formatter its messages are rendered with.
+type target struct {
+	key         string
+	notifier    Notifier
+	preferences Preferences
+	formatter   messageFormatter
+}
+
+// buildTargets creates notifiers from config, falling back to the telegram chat given in arguments.
+func buildTargets() ([]target, error) {
+	if len(config.Targets) == 0 {
+		if chatId == "" || botToken == "" {
+			return nil, errors.New("no notification targets: pass chatId and botToken, configure targets or subscribe chats through the bot")
+		}
+		formatter, err := newMessageFormatter(config.Telegram.ParseMode)
+		if err != nil {
+			return nil, err
+		}
+		return []target{{chatId, telegramNotifier{botToken, chatId, formatter.parseMode()}, Preferences{}, formatter}}, nil
+	}
+	var targets []target
+	for i, targetConfig := range config.Targets {
+		notifier, err := newNotifier(targetConfig)
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %v", i+1, err)
+		}
+		if err := validatePreferences(targetConfig.Preferences); err != nil {
+			return nil, fmt.Errorf("target %d: %v", i+1, err)
+		}
+		var formatter messageFormatter = plainFormatter{}
+		if telegram, ok := notifier.(telegramNotifier); ok {
+			formatter, _ = newMessageFormatter(telegram.parseMode)
+		}
+		targets = append(targets, target{targetKey(targetConfig, notifier), notifier, targetConfig.Preferences, formatter})
+	}
+	return targets, nil
+}
+
+// targetKey identifies a target in the state file, its name or the notifier name when it has none.
+func targetKey(targetConfig TargetConfig, notifier Notifier) string {
+	if targetConfig.Name != "" {
+		return targetConfig.Name
+	}
+	return notifier.Name()
+}
+
+// validateTargets rejects targets sharing a key, e.g. two unnamed slack targets,
+// the second one would be skipped as already notified.
+func validateTargets(targets []TargetConfig) error {
+	keys := make(map[string]int)
+	for i, targetConfig := range targets {
+		notifier, err := newNotifier(targetConfig)
+		if err != nil {
+			return fmt.Errorf("target %d: %v", i+1, err)
+		}
+		key := targetKey(targetConfig, notifier)
+		if first, ok := keys[key]; ok {
+			return fmt.Errorf("targets %d and %d are both %s, give them distinct names", first, i+1, key)
+		}
+		keys[key] = i + 1
+	}
+	return nil
+}
+
+// buildRunTargets are the targets from config or arguments and the chats subscribed through the bot.
+// With the bot set up and no targets of its own, subscribers alone are notified.
+func buildRunTargets(state State) ([]target, error) {
+	if len(config.Targets) == 0 && chatId == "" && hasSubscribers(state) {
+		return subscriberTargets(state, nil), nil
+	}
+	targets, err := buildTargets()
+	if err != nil {
+		return nil, err
+	}
+	return append(targets, subscriberTargets(state, targets)...), nil
+}
+
+func newNotifier(target TargetConfig) (Notifier, error) {
+	switch target.Type {
+	case telegramTargetType:
+		if target.ChatID == "" || target.BotToken == "" {
+			return nil, errors.New("telegram target needs chatId and botToken")
+		}
+		parseMode := config.Telegram.ParseMode
+		if target.ParseMode != nil {
+			parseMode = *target.ParseMode
+		}
+		if _, err := newMessageFormatter(parseMode); err != nil {
+			return nil, err
+		}
+		return telegramNotifier{target.BotToken, target.ChatID, parseMode}, nil
+	case slackTargetType, discordTargetType, webhookTargetType:
+		if target.URL == "" {
+			return nil, fmt.Errorf("%s target needs url", target.Type)
+		}
+		switch target.Type {
+		case slackTargetType:
+			return slackNotifier{target.URL}, nil
+		case discordTargetType:
+			return discordNotifier{target.URL}, nil
+		default:
+			return webhookNotifier{target.URL, target.Secret}, nil
+		}
+	case emailTargetType:
+		if target.SMTPAddr == "" || target.From == "" || len(target.To) == 0 {
+			return nil, errors.New("email target needs smtpAddr, from and to")
+		}
+		return emailNotifier{target.SMTPAddr, target.Username, target.Password, target.From, target.To}, nil
+	default:
+		return nil, fmt.Errorf("unknown target type: %s. available types: %s, %s, %s, %s, %s",
+			target.Type,
+			telegramTargetType,
+			slackTargetType,
+			discordTargetType,
+			emailTargetType,
+			webhookTargetType,
+		)
+	}
+}
+
+type telegramNotifier struct {
+	botToken, chatId, parseMode string
+}
+
+func (n telegramNotifier) Name() string { return "telegram:" + n.chatId }
+
+func (n telegramNotifier) Notify(_ string, message bytes.Buffer) error {
+	return sendMessageToTelegram(message, n.botToken, n.chatId, n.parseMode)
+}
+
+func (n telegramNotifier) NotifyPhotos(photos []chartImage) error {
+	return sendPhotosToTelegram(photos, n.botToken, n.chatId)
+}
+
+func (n telegramNotifier) NotifyDocument(name, caption string, content []byte) error {
+	return sendDocumentToTelegram(name, caption, content, n.botToken, n.chatId)
+}
+
+type slackNotifier struct {
+	url string
+}
+
+func (n slackNotifier) Name() string { return "slack" }
+
+func (n slackNotifier) Notify(subject string, message bytes.Buffer) error {
+	return postJSON(n.url, map[string]string{"text": "*" + subject + "*\n" + message.String()}, nil)
+}
+
+type discordNotifier struct {
+	url string
+}
+
+func (n discordNotifier) Name() string { return "discord" }
+
+// Notify sends the message in parts, discord rejects messages longer than 2000 characters.
+func (n discordNotifier) Notify(subject string, message bytes.Buffer) error {
+	for _, part := range splitMessage("**"+subject+"**\n"+message.String(), discordMessageLimit) {
+		if err := postJSON(n.url, map[string]string{"content": part}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type webhookNotifier struct {
+	url, secret string
+}
+
+func (n webhookNotifier) Name() string { return "webhook" }
+
+// Notify posts the message as JSON. With a secret the body is signed with HMAC-SHA256,
+// hex encoded in the X-Signature-256 header as sha256=<signature>.
+func (n webhookNotifier) Notify(subject string, message bytes.Buffer) error {
+	payload := struct {
+		Subject string    `json:"subject"`
+		Text    string    `json:"text"`
+		SentAt  time.Time `json:"sentAt"`
+	}{subject, message.String(), time.Now().UTC()}
+	var sign func([]byte) (string, string)
+	if n.secret != "" {
+		sign = func(body []byte) (string, string) {
+			return webhookSignature, "sha256=" + signPayload(n.secret, body)
+		}
+	}
+	return postJSON(n.url, payload, sign)
+}
+
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type emailNotifier struct {
+	addr, username, password, from string
+	to                             []string
+}
+
+func (n emailNotifier) Name() string { return "email:" + strings.Join(n.to, ",") }
+
+func (n emailNotifier) Notify(subject string, message bytes.Buffer) error {
+	var auth smtp.Auth
+	if n.username != "" {
+		host, _, _ := strings.Cut(n.addr, ":")
+		auth = smtp.PlainAuth("", n.username, n.password, host)
+	}
+	var body bytes.Buffer
+	body.WriteString(fmt.Sprintf("From: %s\r\n", n.from))
+	body.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(n.to, ", ")))
+	body.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(message.String(), "\n", "\r\n"))
+	if err := smtp.SendMail(n.addr, auth, n.from, n.to, body.Bytes()); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
+
+// postJSON posts payload as JSON, sign returns an extra header computed from the body.
+func postJSON(url string, payload any, sign func(body []byte) (string, string)) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling payload: %v", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sign != nil {
+		req.Header.Set(sign(body))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to send message. Status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// splitMessage cuts text into parts no longer than limit characters, on line boundaries when possible.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	var part strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for len([]rune(line)) > limit {
+			if part.Len() > 0 {
+				parts = append(parts, part.String())
+				part.Reset()
+			}
+			runes := []rune(line)
+			parts = append(parts, string(runes[:limit]))
+			line = string(runes[limit:])
+		}
+		if len([]rune(part.String()))+len([]rune(line)) > limit {
+			parts = append(parts, part.String())
+			part.Reset()
+		}
+		part.WriteString(line)
+	}
+	if part.Len() > 0 {
+		parts = append(parts, part.String())
+	}
+	return parts
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_validateTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []TargetConfig
+		wantErr bool
+	}{
+		{"distinct types", []TargetConfig{{Type: slackTargetType, URL: "https://a"}, {Type: discordTargetType, URL: "https://b"}}, false},
+		{"named targets of one type", []TargetConfig{{Type: slackTargetType, URL: "https://a"}, {Type: slackTargetType, Name: "team", URL: "https://b"}}, false},
+		{"unnamed targets of one type", []TargetConfig{{Type: slackTargetType, URL: "https://a"}, {Type: slackTargetType, URL: "https://b"}}, true},
+		{"name of another target", []TargetConfig{{Type: telegramTargetType, ChatID: "1", BotToken: "t"}, {Type: webhookTargetType, Name: "telegram:1", URL: "https://a"}}, true},
+		{"invalid target", []TargetConfig{{Type: slackTargetType}}, true},
+	}
+	for _, test := range tests {
+		if err := validateTargets(test.targets); (err != nil) != test.wantErr {
+			t.Errorf("%s, got error: %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func Test_buildRunTargets(t *testing.T) {
+	defer func() { config, chatId, botToken = defaultConfig(), "", "" }()
+	subscribed := State{Subscriptions: map[string]Preferences{"42": {}}}
+	tests := []struct {
+		name     string
+		targets  []TargetConfig
+		botToken string
+		state    State
+		want     []string
+		wantErr  bool
+	}{
+		{name: "subscribers only", botToken: "bot", state: subscribed, want: []string{"42"}},
+		{name: "configured target and subscribers", targets: []TargetConfig{{Type: slackTargetType, URL: "https://a"}}, botToken: "bot", state: subscribed, want: []string{"slack", "42"}},
+		{name: "subscriber configured as telegram target", targets: []TargetConfig{{Type: telegramTargetType, ChatID: "42", BotToken: "t"}}, botToken: "bot", state: subscribed, want: []string{"telegram:42"}},
+		{name: "subscribers without bot token", state: subscribed, wantErr: true},
+		{name: "bot without subscribers", botToken: "bot", wantErr: true},
+	}
+	for _, test := range tests {
+		config = defaultConfig()
+		config.Targets, config.Bot.Token = test.targets, test.botToken
+		targets, err := buildRunTargets(test.state)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s, got error: %v, want error: %v", test.name, err, test.wantErr)
+			continue
+		}
+		var keys []string
+		for _, target := range targets {
+			keys = append(keys, target.key)
+		}
+		if !slices.Equal(keys, test.want) {
+			t.Errorf("%s, got: %v != want: %v", test.name, keys, test.want)
+		}
+	}
+}
+
+func Test_httpNotifiers(t *testing.T) {
+	var message bytes.Buffer
+	message.WriteString("Warszawa-Modlin ---> Alicante\n")
+
+	type request struct {
+		path      string
+		body      map[string]any
+		signature string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("body is not JSON: %s", raw)
+		}
+		if r.Header.Get(webhookSignature) != "" && r.Header.Get(webhookSignature) != "sha256="+signPayload("secret", raw) {
+			t.Errorf("wrong webhook signature: %s", r.Header.Get(webhookSignature))
+		}
+		requests = append(requests, request{r.URL.Path, body, r.Header.Get(webhookSignature)})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		notifier Notifier
+		path     string
+		field    string
+		signed   bool
+	}{
+		{
+			name:     "slack incoming webhook",
+			notifier: slackNotifier{server.URL + "/slack"},
+			path:     "/slack",
+			field:    "text",
+		},
+		{
+			name:     "discord incoming webhook",
+			notifier: discordNotifier{server.URL + "/discord"},
+			path:     "/discord",
+			field:    "content",
+		},
+		{
+			name:     "generic webhook signed with hmac",
+			notifier: webhookNotifier{server.URL + "/webhook", "secret"},
+			path:     "/webhook",
+			field:    "text",
+			signed:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests = nil
+			if err := test.notifier.Notify("Ryanair digest", message); err != nil {
+				t.Fatal(err)
+			}
+			if len(requests) != 1 {
+				t.Fatalf("requests, got: %d != want: %d", len(requests), 1)
+			}
+			got := requests[0]
+			if got.path != test.path {
+				t.Errorf("path, got: %s != want: %s", got.path, test.path)
+			}
+			if text, _ := got.body[test.field].(string); !strings.Contains(text, "Alicante") {
+				t.Errorf("%s field does not contain message: %v", test.field, got.body)
+			}
+			if test.signed != (got.signature != "") {
+				t.Errorf("signed, got: %v != want: %v", got.signature != "", test.signed)
+			}
+		})
+	}
+}
+
+func Test_telegramNotifier(t *testing.T) {
+	var chat, text string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottoken/sendMessage" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		chat, text = r.FormValue("chat_id"), r.FormValue("text")
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer server.Close()
+	telegramAPIURL = server.URL
+	defer func() { telegramAPIURL = "https://api.telegram.org" }()
+
+	var message bytes.Buffer
+	message.WriteString("Razem: 150.00zł")
+	if err := (telegramNotifier{"token", "123", plainParseMode}).Notify("Ryanair digest", message); err != nil {
+		t.Fatal(err)
+	}
+	if chat != "123" || text != "Razem: 150.00zł" {
+		t.Errorf("telegram got chat: %s, text: %s", chat, text)
+	}
+}
+
+func Test_emailNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go serveFakeSMTP(listener, received)
+
+	var message bytes.Buffer
+	message.WriteString("Razem: 150.00zł\n")
+	notifier := emailNotifier{addr: listener.Addr().String(), from: "bot@example.com", to: []string{"team@example.com"}}
+	if err := notifier.Notify("Ryanair digest", message); err != nil {
+		t.Fatal(err)
+	}
+	data := <-received
+	if !strings.Contains(data, "Subject: Ryanair digest") || !strings.Contains(data, "Razem: 150.00zł") {
+		t.Errorf("unexpected email:\n%s", data)
+	}
+}
+
+// serveFakeSMTP accepts a single connection and answers just enough SMTP to receive one email.
+func serveFakeSMTP(listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost fake smtp")
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			received <- data.String()
+			reply("250 ok")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
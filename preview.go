@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const previewTargetKey = "preview"
+
+// previewOutput receives messages and tables of a dry run.
+var previewOutput io.Writer = os.Stdout
+
+// stdoutNotifier prints messages of a dry run instead of delivering them to the target named.
+type stdoutNotifier struct {
+	name string
+}
+
+func (n stdoutNotifier) Name() string { return n.name }
+
+func (n stdoutNotifier) Notify(subject string, message bytes.Buffer) error {
+	_, err := fmt.Fprintf(previewOutput, "=== %s for %s ===\n%s\n", subject, n.name, message.String())
+	return err
+}
+
+// buildPreviewTargets are the targets of a run with every notifier replaced by a stdoutNotifier
+// and messages in plain text. Without any configured target the report of default preferences is shown.
+func buildPreviewTargets(state State) ([]target, error) {
+	var targets []target
+	if len(config.Targets) > 0 || chatId != "" {
+		var err error
+		if targets, err = buildTargets(); err != nil {
+			return nil, err
+		}
+	}
+	targets = append(targets, subscriberTargets(state, targets)...)
+	if len(targets) == 0 {
+		return []target{{previewTargetKey, stdoutNotifier{previewTargetKey}, Preferences{}, plainFormatter{}}}, nil
+	}
+	for i := range targets {
+		targets[i].notifier = stdoutNotifier{targets[i].notifier.Name()}
+		targets[i].formatter = plainFormatter{}
+	}
+	return targets, nil
+}
+
+// printReportTables prints the digest of every target as an aligned table.
+func printReportTables(now time.Time, fares legFares, strategy RankingStrategy, targets []target) error {
+	for _, target := range targets {
+		flightsToCompare, err := target.preferences.trips(fares)
+		if err != nil {
+			return fmt.Errorf("%s: %v", target.notifier.Name(), err)
+		}
+		language := target.preferences.Language
+		if language == "" {
+			language = config.Templates.Language
+		}
+		fmt.Fprintf(previewOutput, "=== Ryanair digest for %s ===\n", target.notifier.Name())
+		if err := writeReportTable(previewOutput, buildReport(now, flightsToCompare, strategy), locales[language]); err != nil {
+			return err
+		}
+		fmt.Fprintln(previewOutput)
+	}
+	return nil
+}
+
+// writeReportTable writes offers of the report sections in aligned columns, one trip per row.
+func writeReportTable(w io.Writer, sections []reportSection, l locale) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "BUCKET\tROUTE\tOUTBOUND\tRETURN\tNIGHTS\tOUTBOUND PRICE\tRETURN PRICE\tTOTAL")
+	for _, section := range sections {
+		for _, trip := range section.Offers {
+			nights, _ := trip.nights()
+			fmt.Fprintf(table, "%s\t%s-%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+				l.bucketLabel(section.Bucket),
+				trip.route(), trip.ReturnFlight.ArrivalAirport.IATACode,
+				l.flightDate(trip.AbroadFlight),
+				l.flightDate(trip.ReturnFlight),
+				nights,
+				l.formatPrice(trip.AbroadFlight.Price),
+				l.formatPrice(trip.ReturnFlight.Price),
+				l.formatNumber(trip.totalPrice(), 2)+" zł",
+			)
+		}
+	}
+	return table.Flush()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_buildPreviewTargets(t *testing.T) {
+	defer func(previous Config, previousChat, previousToken string) {
+		config, chatId, botToken = previous, previousChat, previousToken
+	}(config, chatId, botToken)
+	defer func(previous io.Writer) { previewOutput = previous }(previewOutput)
+
+	config = defaultConfig()
+	chatId, botToken = "", ""
+	targets, err := buildPreviewTargets(State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].key != previewTargetKey {
+		t.Fatalf("without credentials, got: %+v", targets)
+	}
+
+	config.Targets = []TargetConfig{
+		{Type: telegramTargetType, ChatID: "123", BotToken: "token"},
+		{Type: slackTargetType, URL: "https://hooks.slack.com/services/T000"},
+	}
+	targets, err = buildPreviewTargets(State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("targets, got: %d != want: %d", len(targets), 2)
+	}
+	var output bytes.Buffer
+	previewOutput = &output
+	for _, target := range targets {
+		if _, ok := target.notifier.(stdoutNotifier); !ok {
+			t.Errorf("%s notifier is not replaced: %T", target.key, target.notifier)
+		}
+		if target.formatter.parseMode() != plainParseMode {
+			t.Errorf("%s messages are not plain text", target.key)
+		}
+		if err := target.notifier.Notify("Ryanair digest", *bytes.NewBufferString("report")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "=== Ryanair digest for telegram:123 ===\nreport\n=== Ryanair digest for slack ===\nreport\n"
+	if output.String() != want {
+		t.Errorf("got: %q != want: %q", output.String(), want)
+	}
+}
+
+func Test_writeReportTable(t *testing.T) {
+	outbound := mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN").Outbound
+	inbound := mockFare("ALC", "WAW", "2024-10-10T16:00:00", 1086, "PLN").Outbound
+	sections := []reportSection{
+		{Bucket: Bucket{Year: 2024, Month: 10}, Offers: []FlightToCompare{{outbound, inbound}}},
+		{Bucket: Bucket{Year: 2024, Month: 11}},
+	}
+
+	var output bytes.Buffer
+	if err := writeReportTable(&output, sections, locales[englishLanguage]); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines, got: %d != want: %d\n%s", len(lines), 2, output.String())
+	}
+	if !strings.HasPrefix(lines[1], "October 2024  WMI-ALC-WAW  2024-10-04 12:00 Fri  2024-10-10 16:00 Thu  6") ||
+		!strings.HasSuffix(lines[1], "1,166.00 zł") {
+		t.Errorf("unexpected row: %q", lines[1])
+	}
+	// columns are aligned with the header
+	if strings.Index(lines[0], "ROUTE") != strings.Index(lines[1], "WMI-ALC-WAW") {
+		t.Errorf("columns are not aligned:\n%s", output.String())
+	}
+}
+
+func Test_fetchLegFares_dryRunLeavesHistory(t *testing.T) {
+	_, closeUpstream := fakeUpstream(t, nil)
+	defer closeUpstream()
+	defer func(previous Config, previousDryRun bool) { config, dryRun = previous, previousDryRun }(config, dryRun)
+	config = defaultConfig()
+	config.History.Path = filepath.Join(t.TempDir(), "history.db")
+	now := time.Date(2024, time.October, 1, 8, 0, 0, 0, time.UTC)
+	flightKey := mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN").Outbound.FlightKey
+
+	for _, test := range []struct {
+		dryRun bool
+		want   int
+	}{{true, 0}, {false, 1}} {
+		dryRun = test.dryRun
+		if _, _, err := fetchLegFares(now, []leg{{"WMI", "ALC"}}); err != nil {
+			t.Fatal(err)
+		}
+		store, err := openHistoryStore(config.History.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		observations, err := store.flightHistory(flightKey)
+		store.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(observations) != test.want {
+			t.Errorf("dry run %v, observations got: %d != want: %d", test.dryRun, len(observations), test.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	totalPriceStrategyName        = "total-price"
+	costPerNightStrategyName      = "cost-per-night"
+	redEyePenaltyStrategyName     = "red-eye-penalty"
+	weekendPreferenceStrategyName = "weekend"
+	weightedStrategyName          = "weighted"
+
+	// departures in [redEyeFromHour, 24) or [0, redEyeToHour) count as red-eye
+	redEyeFromHour = 23
+	redEyeToHour   = 6
+)
+
+// RankingStrategy scores a trip, lower score means better trip.
+type RankingStrategy interface {
+	Name() string
+	Score(trip FlightToCompare) float64
+}
+
+type totalPriceStrategy struct{}
+
+func (totalPriceStrategy) Name() string { return "total price" }
+
+func (totalPriceStrategy) Score(trip FlightToCompare) float64 {
+	return trip.totalPrice()
+}
+
+type costPerNightStrategy struct{}
+
+func (costPerNightStrategy) Name() string { return "cost per night" }
+
+func (costPerNightStrategy) Score(trip FlightToCompare) float64 {
+	nights, err := trip.nights()
+	if err != nil {
+		return math.Inf(1)
+	}
+	return trip.totalPrice() / float64(max(nights, 1))
+}
+
+type redEyePenaltyStrategy struct {
+	penalty float64
+}
+
+func (s redEyePenaltyStrategy) Name() string {
+	return fmt.Sprintf("price + %.2f per red-eye departure", s.penalty)
+}
+
+func (s redEyePenaltyStrategy) Score(trip FlightToCompare) float64 {
+	return trip.totalPrice() + s.penalty*float64(trip.redEyeLegs())
+}
+
+type weekendPreferenceStrategy struct {
+	bonus float64
+}
+
+func (s weekendPreferenceStrategy) Name() string {
+	return fmt.Sprintf("price - %.2f per weekend-friendly leg", s.bonus)
+}
+
+func (s weekendPreferenceStrategy) Score(trip FlightToCompare) float64 {
+	return trip.totalPrice() - s.bonus*float64(trip.weekendLegs())
+}
+
+type weightedStrategy struct {
+	weights RankingWeights
+}
+
+func (s weightedStrategy) Name() string {
+	w := s.weights
+	return fmt.Sprintf("weighted (price %g, per night %g, nights %g, red-eye %g, weekend %g)",
+		w.Price, w.PricePerNight, w.Nights, w.RedEyeLegs, w.WeekendLegs)
+}
+
+func (s weightedStrategy) Score(trip FlightToCompare) float64 {
+	nights, err := trip.nights()
+	if err != nil {
+		return math.Inf(1)
+	}
+	total := trip.totalPrice()
+	return s.weights.Price*total +
+		s.weights.PricePerNight*total/float64(max(nights, 1)) +
+		s.weights.Nights*float64(nights) +
+		s.weights.RedEyeLegs*float64(trip.redEyeLegs()) +
+		s.weights.WeekendLegs*float64(trip.weekendLegs())
+}
+
+func newRankingStrategy(config RankingConfig) (RankingStrategy, error) {
+	switch config.Strategy {
+	case "", totalPriceStrategyName:
+		return totalPriceStrategy{}, nil
+	case costPerNightStrategyName:
+		return costPerNightStrategy{}, nil
+	case redEyePenaltyStrategyName:
+		return redEyePenaltyStrategy{config.RedEyePenalty}, nil
+	case weekendPreferenceStrategyName:
+		return weekendPreferenceStrategy{config.WeekendBonus}, nil
+	case weightedStrategyName:
+		return weightedStrategy{config.Weights}, nil
+	default:
+		return nil, fmt.Errorf("unknown ranking strategy: %s. available strategies: %s, %s, %s, %s, %s",
+			config.Strategy,
+			totalPriceStrategyName,
+			costPerNightStrategyName,
+			redEyePenaltyStrategyName,
+			weekendPreferenceStrategyName,
+			weightedStrategyName,
+		)
+	}
+}
+
+// rankTrips sorts trips in place from the best to the worst score.
+func rankTrips(trips []FlightToCompare, strategy RankingStrategy) {
+	scores := make([]float64, len(trips))
+	for i := range trips {
+		scores[i] = strategy.Score(trips[i])
+	}
+	sort.Stable(scoredTrips{trips, scores})
+}
+
+type scoredTrips struct {
+	trips  []FlightToCompare
+	scores []float64
+}
+
+func (s scoredTrips) Len() int           { return len(s.trips) }
+func (s scoredTrips) Less(i, j int) bool { return s.scores[i] < s.scores[j] }
+func (s scoredTrips) Swap(i, j int) {
+	s.trips[i], s.trips[j] = s.trips[j], s.trips[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
+
+func (trip FlightToCompare) totalPrice() float64 {
+	return trip.AbroadFlight.Price.Value + trip.ReturnFlight.Price.Value
+}
+
+// route identifies the outbound leg of the trip, e.g. WMI-ALC.
+func (trip FlightToCompare) route() string {
+	return trip.AbroadFlight.DepartureAirport.IATACode + "-" + trip.AbroadFlight.ArrivalAirport.IATACode
+}
+
+func (trip FlightToCompare) nights() (int, error) {
+	departure, err := trip.AbroadFlight.departureTime()
+	if err != nil {
+		return 0, err
+	}
+	arrival, err := trip.ReturnFlight.departureTime()
+	if err != nil {
+		return 0, err
+	}
+	departureDay := time.Date(departure.Year(), departure.Month(), departure.Day(), 0, 0, 0, 0, time.UTC)
+	returnDay := time.Date(arrival.Year(), arrival.Month(), arrival.Day(), 0, 0, 0, 0, time.UTC)
+	return int(returnDay.Sub(departureDay).Hours() / 24), nil
+}
+
+func (trip FlightToCompare) redEyeLegs() int {
+	legs := 0
+	for _, flight := range []Outbound{trip.AbroadFlight, trip.ReturnFlight} {
+		departure, err := flight.departureTime()
+		if err != nil {
+			continue
+		}
+		if departure.Hour() >= redEyeFromHour || departure.Hour() < redEyeToHour {
+			legs++
+		}
+	}
+	return legs
+}
+
+// weekendLegs counts outbound flights leaving Thursday-Saturday and return flights leaving Sunday-Monday.
+func (trip FlightToCompare) weekendLegs() int {
+	legs := 0
+	if departure, err := trip.AbroadFlight.departureTime(); err == nil {
+		switch departure.Weekday() {
+		case time.Thursday, time.Friday, time.Saturday:
+			legs++
+		}
+	}
+	if departure, err := trip.ReturnFlight.departureTime(); err == nil {
+		switch departure.Weekday() {
+		case time.Sunday, time.Monday:
+			legs++
+		}
+	}
+	return legs
+}
+
+func (flight Outbound) departureTime() (time.Time, error) {
+	return time.Parse(ryanDateLayout, flight.DepartureDate)
+}
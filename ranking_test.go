@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_rankTrips(t *testing.T) {
+	trips := func() []FlightToCompare {
+		return []FlightToCompare{
+			// 100 total, 7 nights, wednesday -> wednesday, red-eye outbound
+			getMockTrip("2024-10-02T05:30:00", 60, "2024-10-09T12:00:00", 40),
+			// 150 total, 16 nights, friday -> sunday
+			getMockTrip("2024-10-04T12:00:00", 80, "2024-10-20T12:00:00", 70),
+			// 120 total, 3 nights, tuesday -> friday
+			getMockTrip("2024-10-01T12:00:00", 60, "2024-10-04T12:00:00", 60),
+		}
+	}
+	tests := []struct {
+		name   string
+		config RankingConfig
+		want   []float64
+	}{
+		{
+			name:   "rank by total price",
+			config: RankingConfig{Strategy: totalPriceStrategyName},
+			want:   []float64{100, 120, 150},
+		},
+		{
+			name:   "rank by cost per night",
+			config: RankingConfig{Strategy: costPerNightStrategyName},
+			want:   []float64{150, 100, 120},
+		},
+		{
+			name:   "rank with red-eye penalty",
+			config: RankingConfig{Strategy: redEyePenaltyStrategyName, RedEyePenalty: 30},
+			want:   []float64{120, 100, 150},
+		},
+		{
+			name:   "rank with weekend preference",
+			config: RankingConfig{Strategy: weekendPreferenceStrategyName, WeekendBonus: 30},
+			want:   []float64{150, 100, 120},
+		},
+		{
+			name: "rank by weighted score",
+			config: RankingConfig{
+				Strategy: weightedStrategyName,
+				Weights:  RankingWeights{Price: 1, Nights: 10},
+			},
+			want: []float64{120, 100, 150},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy, err := newRankingStrategy(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := trips()
+			rankTrips(got, strategy)
+			for i, trip := range got {
+				if trip.totalPrice() != test.want[i] {
+					t.Errorf("trip %d total price, got: %.2f != want: %.2f", i, trip.totalPrice(), test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_newRankingStrategyUnknown(t *testing.T) {
+	if _, err := newRankingStrategy(RankingConfig{Strategy: "cheapest-ever"}); err == nil {
+		t.Error("expected error for unknown ranking strategy")
+	}
+}
+
+func getMockTrip(departureDate string, departurePrice float64, returnDate string, returnPrice float64) FlightToCompare {
+	return FlightToCompare{
+		AbroadFlight: Outbound{
+			DepartureAirport: Airport{IATACode: "WMI", Name: "Warszawa-Modlin"},
+			ArrivalAirport:   Airport{IATACode: "ALC", Name: "Alicante"},
+			DepartureDate:    departureDate,
+			Price:            Price{Value: departurePrice, CurrencyCode: "PLN", CurrencySymbol: "zł"},
+			FlightKey:        "FR~1001~ ~~WMI~" + departureDate + "~ALC",
+			FlightNumber:     "FR1001",
+		},
+		ReturnFlight: Outbound{
+			DepartureAirport: Airport{IATACode: "ALC", Name: "Alicante"},
+			ArrivalAirport:   Airport{IATACode: "WMI", Name: "Warszawa-Modlin"},
+			DepartureDate:    returnDate,
+			Price:            Price{Value: returnPrice, CurrencyCode: "PLN", CurrencySymbol: "zł"},
+			FlightKey:        "FR~1002~ ~~ALC~" + returnDate + "~WMI",
+			FlightNumber:     "FR1002",
+		},
+	}
+}
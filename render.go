@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// locale holds names and number formatting of a report language.
+type locale struct {
+	months             [12]string
+	weekdays           [7]string
+	week               string
+	decimalSeparator   string
+	thousandsSeparator string
+}
+
+var locales = map[string]locale{
+	polishLanguage: {
+		months: [12]string{
+			"Styczeń", "Luty", "Marzec", "Kwiecień", "Maj", "Czerwiec",
+			"Lipiec", "Sierpień", "Wrzesień", "Październik", "Listopad", "Grudzień",
+		},
+		weekdays:           [7]string{"niedz.", "pon.", "wt.", "śr.", "czw.", "pt.", "sob."},
+		week:               "Tydzień",
+		decimalSeparator:   ",",
+		thousandsSeparator: " ",
+	},
+	englishLanguage: {
+		months: [12]string{
+			"January", "February", "March", "April", "May", "June",
+			"July", "August", "September", "October", "November", "December",
+		},
+		weekdays:           [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		week:               "Week",
+		decimalSeparator:   ".",
+		thousandsSeparator: ",",
+	},
+}
+
+type digestData struct {
+	Ranking  string
+	Sections []reportSection
+	ShowMore bool
+}
+
+type priceDropsData struct {
+	Drops []priceDrop
+}
+
+type alertsData struct {
+	Results []ruleMatches
+}
+
+// tripsData is a bot reply, Search is nil for /cheapest.
+type tripsData struct {
+	Search *searchRequest
+	Trips  []FlightToCompare
+}
+
+// commandFailedData is the reply to a bot command that failed.
+type commandFailedData struct {
+	Command string
+	Error   string
+}
+
+// bookingLink opens booking of the listed flights.
+type bookingLink struct {
+	Flights string
+	URL     string
+}
+
+// newReportTemplate parses built-in templates of the language, empty means templates.language,
+// and the user template on top of them, so it may redefine any of them.
+func newReportTemplate(language string, f messageFormatter) (*template.Template, error) {
+	if language == "" {
+		language = config.Templates.Language
+	}
+	l, ok := locales[language]
+	if !ok {
+		return nil, fmt.Errorf("unknown language: %s. available languages: %s, %s", language, polishLanguage, englishLanguage)
+	}
+	t, err := template.New(language).Funcs(l.templateFuncs(f)).ParseFS(builtinTemplates, "templates/"+language+".tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse built-in templates: %v", err)
+	}
+	if config.Templates.Path != "" {
+		if t, err = t.ParseFiles(config.Templates.Path); err != nil {
+			return nil, fmt.Errorf("could not parse template %s: %v", config.Templates.Path, err)
+		}
+	}
+	return t, nil
+}
+
+// renderTemplate renders the named template in the language, formatted for a telegram parse mode.
+func renderTemplate(name, language string, f messageFormatter, data any) (bytes.Buffer, error) {
+	var message bytes.Buffer
+	t, err := newReportTemplate(language, f)
+	if err != nil {
+		return message, err
+	}
+	if err := t.ExecuteTemplate(&message, name, data); err != nil {
+		return message, fmt.Errorf("could not render %s template: %v", name, err)
+	}
+	return message, nil
+}
+
+func (l locale) templateFuncs(f messageFormatter) template.FuncMap {
+	return template.FuncMap{
+		"escape":       f.escape,
+		"bold":         f.bold,
+		"code":         f.code,
+		"link":         f.link,
+		"arrow":        f.arrow,
+		"separator":    func() string { return f.escape(reportSeparator) },
+		"money":        func(value float64) string { return l.formatNumber(value, 2) + " zł" },
+		"price":        l.formatPrice,
+		"percent":      func(value float64) string { return l.formatNumber(value, 1) + "%" },
+		"flightDate":   l.flightDate,
+		"bucketLabel":  l.bucketLabel,
+		"monthName":    func(month time.Month) string { return l.months[month-1] },
+		"legs":         func(trip FlightToCompare) []Outbound { return []Outbound{trip.AbroadFlight, trip.ReturnFlight} },
+		"total":        FlightToCompare.totalPrice,
+		"dropPercent":  priceDrop.percentChange,
+		"bookingLinks": tripBookingLinks,
+		"join":         func(values []string) string { return strings.Join(values, ",") },
+	}
+}
+
+// formatNumber formats value with the locale separators, e.g. 1 312,45 in polish.
+func (l locale) formatNumber(value float64, decimals int) string {
+	formatted := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(formatted, ".")
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteString(l.thousandsSeparator)
+		}
+		grouped.WriteRune(digit)
+	}
+	if fraction != "" {
+		grouped.WriteString(l.decimalSeparator + fraction)
+	}
+	if value < 0 && strings.Trim(formatted, "0.") != "" {
+		return "-" + grouped.String()
+	}
+	return grouped.String()
+}
+
+func (l locale) formatPrice(price Price) string {
+	symbol := price.CurrencySymbol
+	if symbol == "" {
+		symbol = price.CurrencyCode
+	}
+	return l.formatNumber(price.Value, 2) + " " + symbol
+}
+
+// flightDate formats the departure as date, time and short weekday, e.g. 2024-10-05 19:15 sob.
+func (l locale) flightDate(flight Outbound) string {
+	departure, err := flight.departureTime()
+	if err != nil {
+		return strings.Replace(flight.DepartureDate, "T", " ", 1)
+	}
+	return departure.Format("2006-01-02 15:04") + " " + l.weekdays[departure.Weekday()]
+}
+
+func (l locale) bucketLabel(bucket Bucket) string {
+	if bucket.Week > 0 {
+		return fmt.Sprintf("%s %d, %d", l.week, bucket.Week, bucket.Year)
+	}
+	return fmt.Sprintf("%s %d", l.months[bucket.Month-1], bucket.Year)
+}
+
+// tripBookingLinks labels booking links with flight numbers, both flights share a return booking.
+func tripBookingLinks(trip FlightToCompare) []bookingLink {
+	links := bookingLinks(trip)
+	if len(links) == 1 {
+		return []bookingLink{{trip.AbroadFlight.FlightNumber + ", " + trip.ReturnFlight.FlightNumber, links[0]}}
+	}
+	return []bookingLink{
+		{trip.AbroadFlight.FlightNumber, links[0]},
+		{trip.ReturnFlight.FlightNumber, links[1]},
+	}
+}
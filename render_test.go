@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_locale_formatNumber(t *testing.T) {
+	tests := []struct {
+		language string
+		value    float64
+		decimals int
+		want     string
+	}{
+		{polishLanguage, 312.45, 2, "312,45"},
+		{polishLanguage, 1312.4, 2, "1 312,40"},
+		{polishLanguage, -12.34, 1, "-12,3"},
+		{englishLanguage, 1234567.891, 2, "1,234,567.89"},
+		{englishLanguage, -0.01, 1, "0.0"},
+	}
+	for _, test := range tests {
+		if got := locales[test.language].formatNumber(test.value, test.decimals); got != test.want {
+			t.Errorf("%s %v, got: %q != want: %q", test.language, test.value, got, test.want)
+		}
+	}
+}
+
+func Test_renderReport(t *testing.T) {
+	defer func() { config = defaultConfig() }()
+	sections := []reportSection{
+		{Bucket: Bucket{Year: 2024, Month: time.October}, Offers: []FlightToCompare{
+			getMockTrip("2024-10-05T19:15:00", 1100, "2024-10-12T06:00:00", 212.45),
+		}, More: 3},
+		{Bucket: Bucket{Year: 2024, Week: 45}},
+		// every trip of the month was left out by limits
+		{Bucket: Bucket{Year: 2024, Month: time.November}, More: 2},
+	}
+	userTemplate := filepath.Join(t.TempDir(), "digest.tmpl")
+	if err := os.WriteFile(userTemplate, []byte(`{{define "digest"}}{{range .Sections}}{{bucketLabel .Bucket}};{{end}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		language  string
+		formatter messageFormatter
+		template  string
+		want      []string
+	}{
+		{
+			name:      "polish plain text",
+			language:  polishLanguage,
+			formatter: plainFormatter{},
+			want: []string{
+				"Październik 2024\n",
+				"Warszawa-Modlin ---> Alicante 2024-10-05 19:15 sob. 1 100,00 zł\n",
+				"Razem: 1 312,45 zł\n",
+				"...i 3 więcej\n",
+				"Tydzień 45, 2024\nBrak lotów w tym tygodniu\n",
+				"Listopad 2024\n...i 2 więcej\n",
+			},
+		},
+		{
+			name:      "english html",
+			language:  englishLanguage,
+			formatter: htmlFormatter{},
+			want: []string{
+				"<b>October 2024</b>\n",
+				"Warszawa-Modlin → Alicante <code>2024-10-05 19:15 Sat</code> 1,100.00 zł\n",
+				"Total: <b>1,312.45 zł</b>\n",
+				`<a href="https://www.ryanair.com/`,
+				">Book FR1001, FR1002</a>\n",
+				"No flights for this week\n",
+			},
+		},
+		{
+			name:      "markdownV2 escapes static text",
+			language:  englishLanguage,
+			formatter: markdownV2Formatter{},
+			want: []string{
+				"Total: *1,312\\.45 zł*\n",
+				"\\.\\.\\.and 3 more\n",
+				"\\-\\-\\-\\-",
+			},
+		},
+		{
+			name:      "user template redefines digest",
+			language:  englishLanguage,
+			formatter: plainFormatter{},
+			template:  userTemplate,
+			want:      []string{"October 2024;Week 45, 2024;November 2024;"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config = defaultConfig()
+			config.Templates.Path = test.template
+			message, err := renderReport(sections, totalPriceStrategy{}, test.formatter, test.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(message.String(), want) {
+					t.Errorf("message does not contain %q:\n%s", want, message.String())
+				}
+			}
+		})
+	}
+}
+
+func Test_renderAlerts(t *testing.T) {
+	defer func() { config = defaultConfig() }()
+	trip := getMockTrip("2024-10-05T19:15:00", 100, "2024-10-12T06:00:00", 212.45)
+	data := alertsData{[]ruleMatches{
+		{AlertRule{MaxTotal: 350, MinNights: 3, MaxNights: 7}, []FlightToCompare{trip}},
+		{AlertRule{Name: "May", MaxTotal: 1500, MinNights: 5, Months: []string{"may"}}, []FlightToCompare{trip}},
+	}}
+	tests := []struct {
+		language string
+		want     []string
+	}{
+		{polishLanguage, []string{
+			"Alert cenowy: razem < 350,00 zł, noce: 3-7\n",
+			"Alert cenowy: May (razem < 1 500,00 zł, noce: co najmniej 5, miesiące: may)\n",
+		}},
+		{englishLanguage, []string{
+			"Alert: total < 350.00 zł, 3-7 nights\n",
+			"Alert: May (total < 1,500.00 zł, at least 5 nights, in may)\n",
+		}},
+	}
+	for _, test := range tests {
+		message, err := renderTemplate("alerts", test.language, plainFormatter{}, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(message.String(), want) {
+				t.Errorf("%s, message does not contain %q:\n%s", test.language, want, message.String())
+			}
+		}
+	}
+}
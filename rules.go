@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AlertRule matches trips cheaper than MaxTotal. Zero fields are not checked.
+// Months are either year-months like 2025-05 or English month names like may.
+type AlertRule struct {
+	Name      string   `json:"name"`
+	MaxTotal  float64  `json:"maxTotal"`
+	MinNights int      `json:"minNights"`
+	MaxNights int      `json:"maxNights"`
+	Months    []string `json:"months"`
+}
+
+type ruleMatches struct {
+	Rule  AlertRule
+	Trips []FlightToCompare
+}
+
+func validateAlertRules(rules []AlertRule) error {
+	for i, rule := range rules {
+		if rule.MaxTotal <= 0 {
+			return fmt.Errorf("alert rule %d: maxTotal must be greater than 0", i+1)
+		}
+		if rule.MaxNights > 0 && rule.MinNights > rule.MaxNights {
+			return fmt.Errorf("alert rule %d: minNights greater than maxNights", i+1)
+		}
+		for _, month := range rule.Months {
+			if _, err := time.Parse("2006-01", month); err == nil {
+				continue
+			}
+			if _, ok := parseMonthName(month); !ok {
+				return fmt.Errorf("alert rule %d: unknown month %s", i+1, month)
+			}
+		}
+	}
+	return nil
+}
+
+func (rule AlertRule) matches(trip FlightToCompare) bool {
+	if trip.totalPrice() >= rule.MaxTotal {
+		return false
+	}
+	nights, err := trip.nights()
+	if err != nil {
+		return false
+	}
+	if (rule.MinNights > 0 && nights < rule.MinNights) || (rule.MaxNights > 0 && nights > rule.MaxNights) {
+		return false
+	}
+	if len(rule.Months) == 0 {
+		return true
+	}
+	departure, err := trip.AbroadFlight.departureTime()
+	if err != nil {
+		return false
+	}
+	for _, month := range rule.Months {
+		if month == departure.Format("2006-01") {
+			return true
+		}
+		if name, ok := parseMonthName(month); ok && name == departure.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func parseMonthName(name string) (time.Month, bool) {
+	for month := time.January; month <= time.December; month++ {
+		if strings.EqualFold(name, month.String()) || strings.EqualFold(name, month.String()[:3]) {
+			return month, true
+		}
+	}
+	return 0, false
+}
+
+// evaluateRules returns the best ranked matching trips of every rule with at least one match.
+func evaluateRules(flightsToCompare map[Bucket][]FlightToCompare, rules []AlertRule, strategy RankingStrategy, maxMatches int) []ruleMatches {
+	var results []ruleMatches
+	for _, rule := range rules {
+		var trips []FlightToCompare
+		for _, bucketTrips := range flightsToCompare {
+			for _, trip := range bucketTrips {
+				if rule.matches(trip) {
+					trips = append(trips, trip)
+				}
+			}
+		}
+		if len(trips) == 0 {
+			continue
+		}
+		rankTrips(trips, strategy)
+		if len(trips) > maxMatches {
+			trips = trips[:maxMatches]
+		}
+		results = append(results, ruleMatches{rule, trips})
+	}
+	return results
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_AlertRule_matches(t *testing.T) {
+	rule := AlertRule{MaxTotal: 350, MinNights: 5, MaxNights: 9, Months: []string{"may"}}
+	tests := []struct {
+		name string
+		trip FlightToCompare
+		want bool
+	}{
+		{
+			name: "cheap trip in may",
+			trip: getMockTrip("2025-05-03T12:00:00", 150, "2025-05-10T12:00:00", 150),
+			want: true,
+		},
+		{
+			name: "too expensive",
+			trip: getMockTrip("2025-05-03T12:00:00", 200, "2025-05-10T12:00:00", 150),
+			want: false,
+		},
+		{
+			name: "too short",
+			trip: getMockTrip("2025-05-03T12:00:00", 150, "2025-05-06T12:00:00", 150),
+			want: false,
+		},
+		{
+			name: "wrong month",
+			trip: getMockTrip("2025-06-03T12:00:00", 150, "2025-06-10T12:00:00", 150),
+			want: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rule.matches(test.trip); got != test.want {
+				t.Errorf("rule matches, got: %v != want: %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_validateAlertRules(t *testing.T) {
+	if err := validateAlertRules([]AlertRule{{MaxTotal: 350, Months: []string{"2025-05", "June", "jul"}}}); err != nil {
+		t.Error(err)
+	}
+	if err := validateAlertRules([]AlertRule{{MaxTotal: 350, Months: []string{"maj"}}}); err == nil {
+		t.Error("expected error for unknown month")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	topSelectionMode            = "top"
+	maxPerOutboundSelectionMode = "max-per-outbound"
+	spreadWeeksSelectionMode    = "spread-weeks"
+	paretoSelectionMode         = "pareto"
+)
+
+func validateSelectionConfig(config SelectionConfig) error {
+	switch config.Mode {
+	case "", topSelectionMode, spreadWeeksSelectionMode, paretoSelectionMode:
+		return nil
+	case maxPerOutboundSelectionMode:
+		if config.MaxPerOutbound <= 0 {
+			return fmt.Errorf("selection mode %s needs maxPerOutbound greater than 0", config.Mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown selection mode: %s. available modes: %s, %s, %s, %s",
+			config.Mode,
+			topSelectionMode,
+			maxPerOutboundSelectionMode,
+			spreadWeeksSelectionMode,
+			paretoSelectionMode,
+		)
+	}
+}
+
+// offerLimits caps how many offers are picked in total and for every route.
+type offerLimits struct {
+	total    int
+	perRoute map[string]int
+}
+
+type offerPicker struct {
+	ranked      []FlightToCompare
+	limits      offerLimits
+	routeCounts map[string]int
+	picked      []int
+}
+
+func (p *offerPicker) full() bool {
+	return len(p.picked) >= p.limits.total
+}
+
+// take picks the trip unless the picker is full or the trip route reached its limit.
+func (p *offerPicker) take(i int) bool {
+	if p.full() {
+		return false
+	}
+	route := p.ranked[i].route()
+	if limit, ok := p.limits.perRoute[route]; ok && p.routeCounts[route] >= limit {
+		return false
+	}
+	p.routeCounts[route]++
+	p.picked = append(p.picked, i)
+	return true
+}
+
+// selectOffers picks trips from already ranked trips within limits, keeping their rank order.
+func selectOffers(ranked []FlightToCompare, limits offerLimits, config SelectionConfig) []FlightToCompare {
+	picker := &offerPicker{
+		ranked:      ranked,
+		limits:      limits,
+		routeCounts: make(map[string]int),
+	}
+	switch config.Mode {
+	case maxPerOutboundSelectionMode:
+		pickMaxPerOutbound(picker, config.MaxPerOutbound)
+	case spreadWeeksSelectionMode:
+		pickSpreadWeeks(picker)
+	case paretoSelectionMode:
+		pickPareto(picker)
+	default:
+		for i := 0; i < len(ranked) && !picker.full(); i++ {
+			picker.take(i)
+		}
+	}
+	sort.Ints(picker.picked)
+	offers := make([]FlightToCompare, 0, len(picker.picked))
+	for _, i := range picker.picked {
+		offers = append(offers, ranked[i])
+	}
+	return offers
+}
+
+func pickMaxPerOutbound(picker *offerPicker, maxPerOutbound int) {
+	perOutbound := make(map[string]int)
+	for i, trip := range picker.ranked {
+		if picker.full() {
+			break
+		}
+		key := trip.AbroadFlight.FlightKey
+		if perOutbound[key] >= maxPerOutbound {
+			continue
+		}
+		if picker.take(i) {
+			perOutbound[key]++
+		}
+	}
+}
+
+// pickSpreadWeeks takes the best trip of every departure week in turn until the picker is full.
+func pickSpreadWeeks(picker *offerPicker) {
+	var weeks []int
+	byWeek := make(map[int][]int)
+	for i, trip := range picker.ranked {
+		departure, err := trip.AbroadFlight.departureTime()
+		if err != nil {
+			continue
+		}
+		year, week := departure.ISOWeek()
+		key := year*100 + week
+		if _, ok := byWeek[key]; !ok {
+			weeks = append(weeks, key)
+		}
+		byWeek[key] = append(byWeek[key], i)
+	}
+	sort.Ints(weeks)
+
+	for round := 0; !picker.full(); round++ {
+		left := false
+		for _, week := range weeks {
+			if round < len(byWeek[week]) {
+				picker.take(byWeek[week][round])
+				left = true
+			}
+		}
+		if !left {
+			break
+		}
+	}
+}
+
+// pickPareto keeps trips for which no other trip is both cheaper and at least as long,
+// so every picked trip is the cheapest option for its length.
+func pickPareto(picker *offerPicker) {
+	ranked := picker.ranked
+	nights := make([]int, len(ranked))
+	for i, trip := range ranked {
+		tripNights, err := trip.nights()
+		if err != nil {
+			tripNights = -1
+		}
+		nights[i] = tripNights
+	}
+
+	for i, trip := range ranked {
+		if picker.full() {
+			break
+		}
+		if nights[i] < 0 {
+			continue
+		}
+		dominated := false
+		for j, other := range ranked {
+			if i == j || nights[j] < nights[i] || other.totalPrice() > trip.totalPrice() {
+				continue
+			}
+			if nights[j] > nights[i] || other.totalPrice() < trip.totalPrice() || j < i {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			picker.take(i)
+		}
+	}
+}
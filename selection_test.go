@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_selectOffers(t *testing.T) {
+	// already ranked by total price
+	ranked := []FlightToCompare{
+		getMockTrip("2024-10-01T12:00:00", 50, "2024-10-05T12:00:00", 50),  // 100, 4 nights, week 40
+		getMockTrip("2024-10-01T12:00:00", 50, "2024-10-08T12:00:00", 60),  // 110, 7 nights, week 40
+		getMockTrip("2024-10-01T12:00:00", 50, "2024-10-06T12:00:00", 70),  // 120, 5 nights, week 40
+		getMockTrip("2024-10-08T12:00:00", 60, "2024-10-18T12:00:00", 70),  // 130, 10 nights, week 41
+		getMockTrip("2024-10-15T12:00:00", 70, "2024-10-19T12:00:00", 70),  // 140, 4 nights, week 42
+		getMockTrip("2024-10-08T12:00:00", 60, "2024-10-12T12:00:00", 100), // 160, 4 nights, week 41
+	}
+	tests := []struct {
+		name   string
+		limits offerLimits
+		config SelectionConfig
+		want   []float64
+	}{
+		{
+			name:   "top offers",
+			limits: offerLimits{total: 3},
+			config: SelectionConfig{Mode: topSelectionMode},
+			want:   []float64{100, 110, 120},
+		},
+		{
+			name:   "less trips than offers",
+			limits: offerLimits{total: 10},
+			config: SelectionConfig{Mode: topSelectionMode},
+			want:   []float64{100, 110, 120, 130, 140, 160},
+		},
+		{
+			name:   "at most 1 offer per route",
+			limits: offerLimits{total: 3, perRoute: map[string]int{"WMI-ALC": 1}},
+			config: SelectionConfig{Mode: topSelectionMode},
+			want:   []float64{100},
+		},
+		{
+			name:   "at most 1 offer per outbound flight",
+			limits: offerLimits{total: 3},
+			config: SelectionConfig{Mode: maxPerOutboundSelectionMode, MaxPerOutbound: 1},
+			want:   []float64{100, 130, 140},
+		},
+		{
+			name:   "spread offers across weeks",
+			limits: offerLimits{total: 4},
+			config: SelectionConfig{Mode: spreadWeeksSelectionMode},
+			want:   []float64{100, 110, 130, 140},
+		},
+		{
+			name:   "pareto price versus trip length",
+			limits: offerLimits{total: 5},
+			config: SelectionConfig{Mode: paretoSelectionMode},
+			want:   []float64{100, 110, 130},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := selectOffers(ranked, test.limits, test.config)
+			if len(got) != len(test.want) {
+				t.Fatalf("selected offers length, got: %d != want: %d", len(got), len(test.want))
+			}
+			for i, trip := range got {
+				if trip.totalPrice() != test.want[i] {
+					t.Errorf("offer %d total price, got: %.2f != want: %.2f", i, trip.totalPrice(), test.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	stateLockRetry = 10 * time.Millisecond
+	// stateLockStale is the age of a lock file after which it is taken over, its process crashed
+	stateLockStale = 30 * time.Second
+)
+
+// stateMu serializes changes of the state file within the process, e.g. by concurrent webhook updates.
+var stateMu sync.Mutex
+
+// State is kept between runs in a JSON file.
+type State struct {
+	LastDigest time.Time `json:"lastDigest"`
+	// Notified is keyed by chat and trip key
+	Notified map[string]map[string]NotifiedTrip `json:"notified"`
+	// Subscriptions are preferences of chats subscribed through the bot, keyed by chat
+	Subscriptions map[string]Preferences `json:"subscriptions,omitempty"`
+	// LastRuns are times of the last successful run of daemon jobs, keyed by job name
+	LastRuns map[string]time.Time `json:"lastRuns,omitempty"`
+}
+
+// loadState returns an empty state when no path is given or the state file does not exist yet.
+func loadState(path string) (State, error) {
+	var state State
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("could not read state file: %v", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("error unmarshalling state file %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// saveState writes the state to a temporary file first, so a crash never leaves a truncated state file.
+func saveState(path string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling state: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not create state file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write state file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write state file: %v", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// updateState reloads the state file, changes it and saves it, so fields changed meanwhile by
+// another process, like subscriptions changed through the bot during a digest, are kept.
+func updateState(path string, update func(state *State) error) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	unlock, err := lockStateFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	if err := update(&state); err != nil {
+		return err
+	}
+	return saveState(path, state)
+}
+
+// lockStateFile creates a lock file next to the state file, so processes like the bot and the daemon
+// change it one at a time. It returns a function removing the lock.
+func lockStateFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			lock.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("could not lock state file: %v", err)
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > stateLockStale {
+			os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(stateLockRetry)
+	}
+}
+
+func (state State) digestDue(now time.Time, interval time.Duration) bool {
+	return state.LastDigest.IsZero() || now.Sub(state.LastDigest) >= interval
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_updateState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	// a digest loaded the state before the chats subscribed
+	digestState, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(chat string) {
+			defer wg.Done()
+			err := updateState(path, func(state *State) error {
+				if state.Subscriptions == nil {
+					state.Subscriptions = make(map[string]Preferences)
+				}
+				state.Subscriptions[chat] = Preferences{}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(fmt.Sprint(i))
+	}
+	wg.Wait()
+
+	now := time.Date(2024, time.October, 1, 8, 0, 0, 0, time.UTC)
+	digestState.LastDigest = now
+	if err := updateState(path, func(state *State) error {
+		state.LastDigest = digestState.LastDigest
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Subscriptions) != 10 || !state.LastDigest.Equal(now) {
+		t.Errorf("got %d subscriptions, last digest %v", len(state.Subscriptions), state.LastDigest)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left, stat error: %v", err)
+	}
+}
+
+func Test_lockStateFile_takesOverStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path+".lock", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	crashed := time.Now().Add(-2 * stateLockStale)
+	if err := os.Chtimes(path+".lock", crashed, crashed); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
@@ -22,6 +22,8 @@ type Outbound struct {
 	FlightNumber     string   `json:"flightNumber"`
 	PreviousPrice    *float64 `json:"previousPrice"` // Assuming previousPrice can be null
 	PriceUpdated     int64    `json:"priceUpdated"`
+	// OriginalPrice is the price before conversion to PLN, it is not part of the ryanair response
+	OriginalPrice *Price `json:"-"`
 }
 
 type Airport struct {
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	polishLanguage  = "pl"
+	englishLanguage = "en"
+
+	quietHoursLayout = "15:04"
+)
+
+// defaultRoutes are used by subscribers without routes of their own.
+var defaultRoutes = []string{
+	modlinAirportCode + "-" + alicanteAirportCode,
+	chopinAirportCode + "-" + alicanteAirportCode,
+}
+
+// Preferences personalise the reports of a subscriber. Routes are outbound routes like WMI-ALC,
+// trips may return to any origin of routes with the same destination. Zero values fall back
+// to global settings: default routes, trip duration arguments and no budget.
+type Preferences struct {
+	Routes     []string    `json:"routes,omitempty"`
+	MinNights  int         `json:"minNights,omitempty"`
+	MaxNights  int         `json:"maxNights,omitempty"`
+	MaxTotal   float64     `json:"maxTotal,omitempty"`
+	Language   string      `json:"language,omitempty"`
+	QuietHours *QuietHours `json:"quietHours,omitempty"`
+}
+
+// QuietHours is a daily window, e.g. 22:00 to 07:00, when the subscriber is not notified.
+// Timezone defaults to local time.
+type QuietHours struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// location is the timezone of the quiet hours, time.LoadLocation would take empty for UTC.
+func (quiet QuietHours) location() (*time.Location, error) {
+	if quiet.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(quiet.Timezone)
+}
+
+// leg is a one way connection fetched from ryanair.
+type leg struct {
+	From, To string
+}
+
+// legFares are fares of every fetched leg, priced in PLN.
+type legFares map[leg][]Fare
+
+func validatePreferences(preferences Preferences) error {
+	for _, route := range preferences.Routes {
+		from, to, ok := strings.Cut(route, "-")
+		if !ok || !iataCodePattern.MatchString(from) || !iataCodePattern.MatchString(to) {
+			return fmt.Errorf("route must be like WMI-ALC, got: %s", route)
+		}
+	}
+	if preferences.MinNights < 0 || preferences.MaxNights < 0 ||
+		(preferences.MaxNights > 0 && preferences.MaxNights < preferences.MinNights) {
+		return fmt.Errorf("minNights and maxNights must be positive with minNights <= maxNights, got: %d-%d",
+			preferences.MinNights, preferences.MaxNights)
+	}
+	if preferences.MaxTotal < 0 {
+		return fmt.Errorf("maxTotal must be positive, got: %v", preferences.MaxTotal)
+	}
+	if _, ok := locales[preferences.Language]; preferences.Language != "" && !ok {
+		return fmt.Errorf("unknown language: %s. available languages: %s, %s", preferences.Language, polishLanguage, englishLanguage)
+	}
+	if quiet := preferences.QuietHours; quiet != nil {
+		if _, err := time.Parse(quietHoursLayout, quiet.From); err != nil {
+			return fmt.Errorf("quietHours.from must be like 22:00, got: %s", quiet.From)
+		}
+		if _, err := time.Parse(quietHoursLayout, quiet.To); err != nil {
+			return fmt.Errorf("quietHours.to must be like 07:00, got: %s", quiet.To)
+		}
+		if _, err := quiet.location(); err != nil {
+			return fmt.Errorf("unknown quietHours.timezone: %s", quiet.Timezone)
+		}
+	}
+	return nil
+}
+
+func (preferences Preferences) routes() []string {
+	if len(preferences.Routes) == 0 {
+		return defaultRoutes
+	}
+	return preferences.Routes
+}
+
+// legs are the outbound legs of the routes and the return legs from every destination to its origins.
+func (preferences Preferences) legs() []leg {
+	var legs []leg
+	for _, route := range preferences.routes() {
+		from, to, _ := strings.Cut(route, "-")
+		legs = append(legs, leg{from, to})
+	}
+	for _, destination := range preferences.destinations() {
+		for _, origin := range preferences.origins(destination) {
+			legs = append(legs, leg{destination, origin})
+		}
+	}
+	return legs
+}
+
+func (preferences Preferences) destinations() []string {
+	var destinations []string
+	for _, route := range preferences.routes() {
+		_, to, _ := strings.Cut(route, "-")
+		if !slices.Contains(destinations, to) {
+			destinations = append(destinations, to)
+		}
+	}
+	return destinations
+}
+
+func (preferences Preferences) origins(destination string) []string {
+	var origins []string
+	for _, route := range preferences.routes() {
+		from, to, _ := strings.Cut(route, "-")
+		if to == destination {
+			origins = append(origins, from)
+		}
+	}
+	return origins
+}
+
+// trips pairs fares of the subscriber routes into trips within its duration bounds and budget.
+// Explicit bounds count calendar nights, inclusive, like /search of the bot.
+func (preferences Preferences) trips(fares legFares) (map[Bucket][]FlightToCompare, error) {
+	flights := make(map[Bucket][]FlightToCompare)
+	for _, destination := range preferences.destinations() {
+		var outboundFares, returnFares []Fare
+		for _, origin := range preferences.origins(destination) {
+			outboundFares = append(outboundFares, fares[leg{origin, destination}]...)
+			returnFares = append(returnFares, fares[leg{destination, origin}]...)
+		}
+		var paired map[Bucket][]FlightToCompare
+		var err error
+		if preferences.MinNights == 0 && preferences.MaxNights == 0 {
+			paired, err = getFlightsToCompare(outboundFares, returnFares)
+		} else {
+			paired, err = pairNights(outboundFares, returnFares, preferences.MinNights, preferences.MaxNights)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for bucket, trips := range paired {
+			for _, trip := range trips {
+				if preferences.MaxTotal == 0 || trip.totalPrice() <= preferences.MaxTotal {
+					flights[bucket] = append(flights[bucket], trip)
+				}
+			}
+		}
+	}
+	return flights, nil
+}
+
+// direction are fares of every leg flown one way between a destination and the origins of its routes.
+type direction struct {
+	name  string
+	fares []Fare
+}
+
+// directions are the outbound and return directions of every destination, e.g. WMI, WAW → ALC.
+func (preferences Preferences) directions(fares legFares) []direction {
+	var directions []direction
+	for _, destination := range preferences.destinations() {
+		origins := preferences.origins(destination)
+		var outboundFares, returnFares []Fare
+		for _, origin := range origins {
+			outboundFares = append(outboundFares, fares[leg{origin, destination}]...)
+			returnFares = append(returnFares, fares[leg{destination, origin}]...)
+		}
+		directions = append(directions,
+			direction{strings.Join(origins, ", ") + " → " + destination, outboundFares},
+			direction{destination + " → " + strings.Join(origins, ", "), returnFares},
+		)
+	}
+	return directions
+}
+
+// pairNights pairs fares into trips of minNights to maxNights calendar nights, zero maxNights means
+// the maximal trip duration argument.
+func pairNights(outboundFares, returnFares []Fare, minNights, maxNights int) (map[Bucket][]FlightToCompare, error) {
+	if maxNights == 0 {
+		maxNights = maxTripDurationInDays
+	}
+	// pairTrips bounds are exclusive durations, nights are counted below by calendar days
+	paired, err := pairTrips(outboundFares, returnFares, minNights-1, maxNights+1)
+	if err != nil {
+		return nil, err
+	}
+	for bucket, trips := range paired {
+		paired[bucket] = slices.DeleteFunc(trips, func(trip FlightToCompare) bool {
+			nights, err := trip.nights()
+			return err != nil || nights < minNights || nights > maxNights
+		})
+	}
+	return paired, nil
+}
+
+// quiet is true during the subscriber quiet hours.
+func (preferences Preferences) quiet(now time.Time) bool {
+	quiet := preferences.QuietHours
+	if quiet == nil {
+		return false
+	}
+	location, err := quiet.location()
+	if err != nil {
+		return false
+	}
+	from, errFrom := time.Parse(quietHoursLayout, quiet.From)
+	to, errTo := time.Parse(quietHoursLayout, quiet.To)
+	if errFrom != nil || errTo != nil {
+		return false
+	}
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	start, end := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// fetchLegFares fetches every distinct leg once, records fares in history unless it is a dry run,
+// and converts them to PLN. It returns the euro rate used.
+func fetchLegFares(now time.Time, legs []leg) (legFares, float64, error) {
+	startDate, endDate := searchHorizon(now)
+	fares := make(legFares)
+	for _, l := range legs {
+		if _, fetched := fares[l]; fetched {
+			continue
+		}
+		fetched, err := getFares([]string{l.From}, []string{l.To}, startDate, endDate)
+		if err != nil {
+			return nil, 0, err
+		}
+		fares[l] = fetched
+	}
+	// a dry run leaves history as it was, price drops compare with the previous real run
+	if config.History.Path != "" && !dryRun {
+		var all [][]Fare
+		for _, f := range fares {
+			all = append(all, f)
+		}
+		if err := recordFareHistory(now, all...); err != nil {
+			slog.Error("Could not record fare history", "error", err)
+		}
+	}
+	euroRate, err := getEuroRate()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, f := range fares {
+		convertFaresToPLN(f, euroRate)
+	}
+	return fares, euroRate, nil
+}
+
+// allLegs are the distinct legs wanted by any of the preferences.
+func allLegs(wanted []Preferences) []leg {
+	var legs []leg
+	for _, preferences := range wanted {
+		for _, l := range preferences.legs() {
+			if !slices.Contains(legs, l) {
+				legs = append(legs, l)
+			}
+		}
+	}
+	return legs
+}
+
+// updateSubscriptions changes chats subscribed through the bot in the state file.
+func updateSubscriptions(update func(subscriptions map[string]Preferences) error) error {
+	if config.StatePath == "" {
+		return errors.New("subscriptions need statePath in config file")
+	}
+	return updateState(config.StatePath, func(state *State) error {
+		if state.Subscriptions == nil {
+			state.Subscriptions = make(map[string]Preferences)
+		}
+		return update(state.Subscriptions)
+	})
+}
+
+// updatePreferences changes preferences of a chat subscribed through the bot.
+func updatePreferences(chat string, update func(preferences *Preferences) error) error {
+	return updateSubscriptions(func(subscriptions map[string]Preferences) error {
+		preferences, ok := subscriptions[chat]
+		if !ok {
+			return errors.New("this chat is not subscribed, see /subscribe")
+		}
+		if err := update(&preferences); err != nil {
+			return err
+		}
+		if err := validatePreferences(preferences); err != nil {
+			return err
+		}
+		subscriptions[chat] = preferences
+		return nil
+	})
+}
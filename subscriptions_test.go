@@ -0,0 +1,136 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_Preferences_trips(t *testing.T) {
+	fares := legFares{
+		{"WMI", "ALC"}: {mockFare("WMI", "ALC", "2024-10-04T12:00:00", 80, "PLN")},
+		{"WAW", "ALC"}: {mockFare("WAW", "ALC", "2024-10-05T12:00:00", 150, "PLN")},
+		{"ALC", "WMI"}: {mockFare("ALC", "WMI", "2024-10-10T12:00:00", 90, "PLN")},
+		{"ALC", "WAW"}: {mockFare("ALC", "WAW", "2024-10-13T12:00:00", 60, "PLN")},
+		{"KRK", "BCN"}: {mockFare("KRK", "BCN", "2024-10-04T12:00:00", 100, "PLN")},
+		{"BCN", "KRK"}: {mockFare("BCN", "KRK", "2024-10-09T12:00:00", 100, "PLN")},
+	}
+	tests := []struct {
+		name        string
+		preferences Preferences
+		want        []string
+	}{
+		{
+			name:        "default routes return to any warsaw airport",
+			preferences: Preferences{},
+			want:        []string{"WMI-ALC-WMI", "WMI-ALC-WAW", "WAW-ALC-WMI", "WAW-ALC-WAW"},
+		},
+		{
+			name:        "own route",
+			preferences: Preferences{Routes: []string{"KRK-BCN"}},
+			want:        []string{"KRK-BCN-KRK"},
+		},
+		{
+			name:        "nights bounds are inclusive",
+			preferences: Preferences{MinNights: 6, MaxNights: 8},
+			want:        []string{"WMI-ALC-WMI", "WAW-ALC-WAW"},
+		},
+		{
+			name:        "budget",
+			preferences: Preferences{MaxTotal: 170},
+			want:        []string{"WMI-ALC-WMI", "WMI-ALC-WAW"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flightsToCompare, err := test.preferences.trips(fares)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, trips := range flightsToCompare {
+				for _, trip := range trips {
+					got = append(got, trip.route()+"-"+trip.ReturnFlight.ArrivalAirport.IATACode)
+				}
+			}
+			slices.Sort(got)
+			want := slices.Clone(test.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("trips, got: %v != want: %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_allLegs(t *testing.T) {
+	wanted := []Preferences{
+		{},
+		{Routes: []string{"WMI-ALC", "KRK-ALC"}},
+	}
+	want := []leg{
+		{"WMI", "ALC"}, {"WAW", "ALC"}, {"ALC", "WMI"}, {"ALC", "WAW"},
+		{"KRK", "ALC"}, {"ALC", "KRK"},
+	}
+	if got := allLegs(wanted); !slices.Equal(got, want) {
+		t.Errorf("legs, got: %v != want: %v", got, want)
+	}
+}
+
+func Test_Preferences_quiet(t *testing.T) {
+	overnight := Preferences{QuietHours: &QuietHours{From: "22:00", To: "07:00", Timezone: "UTC"}}
+	afternoon := Preferences{QuietHours: &QuietHours{From: "13:00", To: "15:30", Timezone: "UTC"}}
+	defer func(previous *time.Location) { time.Local = previous }(time.Local)
+	time.Local = time.FixedZone("CEST", 2*60*60)
+	local := Preferences{QuietHours: &QuietHours{From: "22:00", To: "07:00"}}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.October, 1, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name        string
+		preferences Preferences
+		now         time.Time
+		want        bool
+	}{
+		{"no quiet hours", Preferences{}, at(23, 0), false},
+		{"overnight before midnight", overnight, at(23, 0), true},
+		{"overnight after midnight", overnight, at(6, 59), true},
+		{"overnight end is not quiet", overnight, at(7, 0), false},
+		{"afternoon inside", afternoon, at(15, 29), true},
+		{"afternoon outside", afternoon, at(12, 0), false},
+		{"no timezone is local time", local, at(20, 30), true},
+		{"no timezone is not UTC", local, at(5, 30), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.preferences.quiet(test.now); got != test.want {
+				t.Errorf("quiet, got: %v != want: %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_validatePreferences(t *testing.T) {
+	valid := []Preferences{
+		{},
+		{Routes: []string{"KRK-BCN"}, MinNights: 5, MaxNights: 9, MaxTotal: 500, Language: englishLanguage},
+		{QuietHours: &QuietHours{From: "22:00", To: "07:00", Timezone: "Europe/Warsaw"}},
+	}
+	invalid := []Preferences{
+		{Routes: []string{"KRK"}},
+		{MinNights: 9, MaxNights: 5},
+		{Language: "de"},
+		{QuietHours: &QuietHours{From: "10pm", To: "07:00"}},
+		{QuietHours: &QuietHours{From: "22:00", To: "07:00", Timezone: "Mars/Olympus"}},
+	}
+	for _, preferences := range valid {
+		if err := validatePreferences(preferences); err != nil {
+			t.Errorf("%+v: %v", preferences, err)
+		}
+	}
+	for _, preferences := range invalid {
+		if err := validatePreferences(preferences); err == nil {
+			t.Errorf("%+v: expected error", preferences)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	telegramMessageLimit = 4096
+	telegramMaxRetries   = 3
+	// telegramMediaGroupLimit is the most photos sendMediaGroup accepts
+	telegramMediaGroupLimit = 10
+	reportSeparator         = "------------------------------------------\n"
+)
+
+// telegramSleep waits before retrying a rate limited request, replaced in tests.
+var telegramSleep = time.Sleep
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func postTelegramForm(botToken, method string, data url.Values) (json.RawMessage, error) {
+	return callTelegram(botToken, method, func() (io.Reader, string, error) {
+		return strings.NewReader(data.Encode()), "application/x-www-form-urlencoded", nil
+	})
+}
+
+// telegramFile is a file uploaded in the field of a multipart request.
+type telegramFile struct {
+	field, name string
+	content     []byte
+}
+
+// postTelegramMultipart calls a bot API method uploading files along with form fields.
+func postTelegramMultipart(botToken, method string, fields map[string]string, files []telegramFile) (json.RawMessage, error) {
+	return callTelegram(botToken, method, func() (io.Reader, string, error) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, value := range fields {
+			if err := writer.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+		}
+		for _, file := range files {
+			part, err := writer.CreateFormFile(file.field, file.name)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(file.content); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return &body, writer.FormDataContentType(), nil
+	})
+}
+
+// sendPhotosToTelegram sends charts with sendPhoto, or with sendMediaGroup in albums of up to 10 photos.
+func sendPhotosToTelegram(photos []chartImage, botToken, chatId string) error {
+	for len(photos) > 0 {
+		album := photos[:min(len(photos), telegramMediaGroupLimit)]
+		photos = photos[len(album):]
+		if len(album) == 1 {
+			_, err := postTelegramMultipart(botToken, "sendPhoto",
+				map[string]string{"chat_id": chatId, "caption": album[0].Caption},
+				[]telegramFile{{"photo", "chart.png", album[0].PNG}},
+			)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		type inputMediaPhoto struct {
+			Type    string `json:"type"`
+			Media   string `json:"media"`
+			Caption string `json:"caption"`
+		}
+		var media []inputMediaPhoto
+		var files []telegramFile
+		for i, photo := range album {
+			field := fmt.Sprintf("chart%d", i)
+			media = append(media, inputMediaPhoto{"photo", "attach://" + field, photo.Caption})
+			files = append(files, telegramFile{field, field + ".png", photo.PNG})
+		}
+		encoded, err := json.Marshal(media)
+		if err != nil {
+			return err
+		}
+		_, err = postTelegramMultipart(botToken, "sendMediaGroup", map[string]string{"chat_id": chatId, "media": string(encoded)}, files)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendDocumentToTelegram uploads content as a file named name with sendDocument.
+func sendDocumentToTelegram(name, caption string, content []byte, botToken, chatId string) error {
+	_, err := postTelegramMultipart(botToken, "sendDocument",
+		map[string]string{"chat_id": chatId, "caption": caption},
+		[]telegramFile{{"document", name, content}},
+	)
+	return err
+}
+
+// callTelegram calls a bot API method and waits out 429 responses for the retry_after they return.
+// body is called again for every attempt, so readers are never reused.
+func callTelegram(botToken, method string, body func() (io.Reader, string, error)) (json.RawMessage, error) {
+	u := fmt.Sprintf("%s/bot%s/%s", telegramAPIURL, botToken, method)
+	for attempt := 0; ; attempt++ {
+		reader, contentType, err := body()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("POST", u, reader)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			// the url of the error contains the bot token, only the cause is kept
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, fmt.Errorf("error sending request to telegram %s: %v", method, err)
+		}
+		var response telegramResponse
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < telegramMaxRetries {
+			retryAfter := max(response.Parameters.RetryAfter, 1)
+			telegramSleep(time.Duration(retryAfter) * time.Second)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to call telegram %s. Status code: %d %s", method, resp.StatusCode, response.Description)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling telegram response: %v", err)
+		}
+		return response.Result, nil
+	}
+}
+
+// splitTelegramMessage cuts the message into parts fitting telegram limit, preferring report
+// section boundaries, then trips, then lines.
+func splitTelegramMessage(text string) []string {
+	return splitOnBoundaries(text, telegramMessageLimit, []string{
+		reportSeparator,
+		markdownV2Formatter{}.escape(reportSeparator),
+		"\n\n",
+	})
+}
+
+func splitOnBoundaries(text string, limit int, separators []string) []string {
+	if len([]rune(text)) <= limit {
+		return []string{text}
+	}
+	if len(separators) == 0 {
+		return splitMessage(text, limit)
+	}
+	var parts []string
+	var part string
+	for _, chunk := range strings.SplitAfter(text, separators[0]) {
+		if chunk == "" {
+			continue
+		}
+		if len([]rune(part))+len([]rune(chunk)) <= limit {
+			part += chunk
+			continue
+		}
+		if part != "" {
+			parts = append(parts, part)
+			part = ""
+		}
+		if len([]rune(chunk)) > limit {
+			parts = append(parts, splitOnBoundaries(chunk, limit, separators[1:])...)
+			continue
+		}
+		part = chunk
+	}
+	if part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
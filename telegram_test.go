@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_splitTelegramMessage(t *testing.T) {
+	section := strings.Repeat("Warszawa-Modlin ---> Alicante 2024-10-05 19:15:00 95.00zł\n\n", 30) + reportSeparator
+	message := strings.Repeat(section, 4)
+	parts := splitTelegramMessage(message)
+	if len(parts) < 2 {
+		t.Fatalf("message of %d characters was not split", len([]rune(message)))
+	}
+	if strings.Join(parts, "") != message {
+		t.Error("parts joined together differ from the message")
+	}
+	for i, part := range parts {
+		if len([]rune(part)) > telegramMessageLimit {
+			t.Errorf("part %d is longer than telegram limit: %d", i, len([]rune(part)))
+		}
+		if !strings.HasSuffix(part, reportSeparator) {
+			t.Errorf("part %d was not split on section boundary", i)
+		}
+	}
+}
+
+func Test_sendMessageToTelegramRetry(t *testing.T) {
+	var texts []string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`)
+			return
+		}
+		texts = append(texts, r.FormValue("text"))
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer server.Close()
+	telegramAPIURL = server.URL
+	var slept []time.Duration
+	telegramSleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() {
+		telegramAPIURL = "https://api.telegram.org"
+		telegramSleep = time.Sleep
+	}()
+
+	var message bytes.Buffer
+	message.WriteString(strings.Repeat("a", 4000) + "\n" + strings.Repeat("b", 200) + "\n")
+	if err := sendMessageToTelegram(message, "token", "123", plainParseMode); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Errorf("waited for retry, got: %v", slept)
+	}
+	if len(texts) != 2 || !strings.HasPrefix(texts[0], "a") || !strings.HasPrefix(texts[1], "b") {
+		t.Errorf("messages sent out of order: %q", texts)
+	}
+}
+
+func Test_callTelegram_transportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	telegramAPIURL = server.URL
+	defer func() { telegramAPIURL = "https://api.telegram.org" }()
+
+	_, err := postTelegramForm("123:secret-token", "sendMessage", url.Values{})
+	if err == nil {
+		t.Fatal("expected error for closed server")
+	}
+	if strings.Contains(err.Error(), "secret-token") || !strings.Contains(err.Error(), "refused") {
+		t.Errorf("error should keep the cause without the bot token, got: %v", err)
+	}
+}
+
+func Test_sendPhotosToTelegram(t *testing.T) {
+	type call struct {
+		method, chat string
+		files        int
+		media        string
+	}
+	var calls []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("%s is not a multipart upload: %v", r.URL.Path, err)
+		}
+		calls = append(calls, call{strings.TrimPrefix(r.URL.Path, "/bottoken/"), r.FormValue("chat_id"), len(r.MultipartForm.File), r.FormValue("media")})
+		fmt.Fprint(w, `{"ok":true,"result":[]}`)
+	}))
+	defer server.Close()
+	telegramAPIURL = server.URL
+	defer func() { telegramAPIURL = "https://api.telegram.org" }()
+
+	var photos []chartImage
+	for i := 0; i <= telegramMediaGroupLimit; i++ {
+		photos = append(photos, chartImage{fmt.Sprintf("chart %d", i), []byte("png")})
+	}
+	if err := sendPhotosToTelegram(photos, "token", "123"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("calls, got: %+v", calls)
+	}
+	if calls[0].method != "sendMediaGroup" || calls[0].files != telegramMediaGroupLimit || calls[0].chat != "123" ||
+		!strings.Contains(calls[0].media, `{"type":"photo","media":"attach://chart9","caption":"chart 9"}`) {
+		t.Errorf("unexpected album: %+v", calls[0])
+	}
+	if calls[1].method != "sendPhoto" || calls[1].files != 1 {
+		t.Errorf("the last photo was not sent with sendPhoto: %+v", calls[1])
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	webhookSecretHeader      = "X-Telegram-Bot-Api-Secret-Token"
+	webhookShutdownTimeout   = 30 * time.Second
+	webhookMaxUpdateBytes    = 1 << 20
+	webhookReadHeaderTimeout = 10 * time.Second
+)
+
+// webhookHandler receives telegram updates and answers them with the bot command router.
+// Updates are handled in the background, so slow searches do not make telegram resend them.
+type webhookHandler struct {
+	bot         *bot
+	secretToken string
+	pending     sync.WaitGroup
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(h.secretToken)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var update telegramUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookMaxUpdateBytes)).Decode(&update); err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+	h.pending.Add(1)
+	go func() {
+		defer h.pending.Done()
+		h.bot.handleUpdate(update)
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveWebhook serves telegram updates until ctx is done, then waits for updates being handled.
+func (b *bot) serveWebhook(ctx context.Context) error {
+	webhook := config.Bot.Webhook
+	if webhook.SecretToken == "" {
+		return errors.New("webhook needs bot.webhook.secretToken in config file")
+	}
+	handler := &webhookHandler{bot: b, secretToken: webhook.SecretToken}
+	mux := http.NewServeMux()
+	mux.Handle(webhook.Path, handler)
+	server := &http.Server{Addr: webhook.ListenAddr, Handler: mux, ReadHeaderTimeout: webhookReadHeaderTimeout}
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Serving telegram webhook", "addr", webhook.ListenAddr, "path", webhook.Path)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	handler.pending.Wait()
+	return err
+}
+
+// setWebhook registers bot.webhook.url with telegram, pending updates are kept.
+func (b *bot) setWebhook() error {
+	webhook := config.Bot.Webhook
+	if webhook.URL == "" || webhook.SecretToken == "" {
+		return errors.New("set-webhook needs bot.webhook.url and bot.webhook.secretToken in config file")
+	}
+	data := url.Values{}
+	data.Set("url", webhook.URL)
+	data.Set("secret_token", webhook.SecretToken)
+	data.Set("allowed_updates", `["message"]`)
+	if _, err := postTelegramForm(b.token, "setWebhook", data); err != nil {
+		return err
+	}
+	slog.Info("Webhook set", "url", webhook.URL)
+	return nil
+}
+
+// deleteWebhook removes the webhook, so the bot can poll for updates again.
+func (b *bot) deleteWebhook() error {
+	if _, err := postTelegramForm(b.token, "deleteWebhook", url.Values{}); err != nil {
+		return err
+	}
+	slog.Info("Webhook deleted")
+	return nil
+}
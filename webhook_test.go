@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_webhookHandler(t *testing.T) {
+	replies, closeUpstream := fakeUpstream(t, nil)
+	defer closeUpstream()
+
+	strategy, _ := newRankingStrategy(RankingConfig{Strategy: totalPriceStrategyName})
+	handler := &webhookHandler{bot: &bot{"token", []string{"123"}, strategy, plainFormatter{}}, secretToken: "secret"}
+	update, _ := json.Marshal(newTelegramUpdate(1, 123, "/help"))
+
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		want   int
+	}{
+		{
+			name:   "missing secret token",
+			method: http.MethodPost,
+			body:   string(update),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "wrong secret token",
+			method: http.MethodPost,
+			secret: "guess",
+			body:   string(update),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "not an update",
+			method: http.MethodPost,
+			secret: "secret",
+			body:   "{",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "get request",
+			method: http.MethodGet,
+			secret: "secret",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "update with secret token",
+			method: http.MethodPost,
+			secret: "secret",
+			body:   string(update),
+			want:   http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/telegram/webhook", strings.NewReader(test.body))
+			if test.secret != "" {
+				req.Header.Set(webhookSecretHeader, test.secret)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != test.want {
+				t.Errorf("status, got: %d != want: %d", recorder.Code, test.want)
+			}
+		})
+	}
+	handler.pending.Wait()
+	if len(replies["123"]) != 1 || !strings.HasPrefix(replies["123"][0], "Komendy:") {
+		t.Errorf("only the verified update should be answered with help, got: %v", replies["123"])
+	}
+}
+
+func Test_bot_setWebhook(t *testing.T) {
+	calls := make(map[string]url.Values)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		calls[strings.TrimPrefix(r.URL.Path, "/bottoken/")] = r.PostForm
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}))
+	defer server.Close()
+	telegramAPIURL = server.URL
+	defer func() { telegramAPIURL = "https://api.telegram.org" }()
+	defer func(previous Config) { config = previous }(config)
+	config = defaultConfig()
+	config.Bot.Webhook.URL = "https://bot.example.com/telegram/webhook"
+	config.Bot.Webhook.SecretToken = "secret"
+
+	b := &bot{token: "token"}
+	if err := b.setWebhook(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.deleteWebhook(); err != nil {
+		t.Fatal(err)
+	}
+	set := calls["setWebhook"]
+	if set.Get("url") != config.Bot.Webhook.URL || set.Get("secret_token") != "secret" {
+		t.Errorf("setWebhook got: %v", set)
+	}
+	if _, ok := calls["deleteWebhook"]; !ok {
+		t.Error("deleteWebhook was not called")
+	}
+}